@@ -0,0 +1,416 @@
+// 请求参数绑定到结构体
+package httpserver
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 参数来源 tag，按顺序查找，第一个有值的来源生效：
+//
+//	type UserDto struct {
+//		Id    int64     `path:"id"`
+//		Page  int       `query:"page"`
+//		Token string    `header:"X-Token"`
+//		Name  string    `form:"name"`
+//		Sid   string    `cookie:"sid"`
+//		Birth time.Time `query:"birth" time_format:"2006-01-02"`
+//		Email string    `json:"email"`
+//	}
+//
+// json/xml/yaml/msgpack/protobuf 等 body 数据按 Content-Type 选择解码器整体解码，然后再按上面的 tag 逐个字段覆盖
+var bindSources = []string{"path", "query", "form", "header", "cookie"}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Content-Type 中的 mime 部分，不含 charset 等参数
+func (req *ReqStruct) ContentType() string {
+	contentType := req.request.Header.Get("Content-Type")
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return mediaType
+}
+
+// 将 path、query、form、header、cookie、body 中的参数一次性绑定到结构体
+// dto := &UserDto{}
+// err := ctx.Req.Bind(dto)
+// obj 不是结构体时（如 csv 绑定到切片）只解码 body
+func (req *ReqStruct) Bind(obj any) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("bind: obj must be a non-nil pointer")
+	}
+	if err := req.bindBody(obj); err != nil {
+		return err
+	}
+	if rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	query := req.request.URL.Query()
+	_, err := req.bindStruct(rv.Elem(), query)
+	return err
+}
+
+// 请求 body 解码器
+type BodyDecoder func(body []byte, obj any) error
+
+var (
+	decodersMux sync.RWMutex
+	decoders    = map[string]BodyDecoder{
+		MIMEJSON:     json.Unmarshal,
+		MIMEXML:      xml.Unmarshal,
+		MIMEXML2:     xml.Unmarshal,
+		MIMEYAML:     yaml.Unmarshal,
+		MIMEYAML2:    yaml.Unmarshal,
+		"text/yaml":  yaml.Unmarshal,
+		MIMEMsgPack:  msgpack.Unmarshal,
+		MIMEMsgPack2: msgpack.Unmarshal,
+		MIMEProtoBuf: decodeProtoBuf,
+		MIMECSV:      decodeCsv,
+	}
+)
+
+// 注册 body 解码器，Bind 时按请求的 Content-Type 选择，同一类型重复注册会覆盖
+func RegisterDecoder(contentType string, decoder BodyDecoder) {
+	decodersMux.Lock()
+	defer decodersMux.Unlock()
+	decoders[strings.ToLower(contentType)] = decoder
+}
+
+func decoderFor(contentType string) (BodyDecoder, bool) {
+	decodersMux.RLock()
+	defer decodersMux.RUnlock()
+	if decoder, ok := decoders[contentType]; ok {
+		return decoder, true
+	}
+	// application/vnd.api+json、application/atom+xml 这类结构化语法后缀
+	switch {
+	case strings.HasSuffix(contentType, "+json"):
+		return decoders[MIMEJSON], true
+	case strings.HasSuffix(contentType, "+xml"):
+		return decoders[MIMEXML], true
+	case strings.HasSuffix(contentType, "+yaml"):
+		return decoders[MIMEYAML], true
+	}
+	return nil, false
+}
+
+// 根据 Content-Type 选择 body 解码器，表单类的请求由 form tag 逐个字段绑定
+func (req *ReqStruct) bindBody(obj any) error {
+	if req.request.Body == nil || req.request.Body == http.NoBody {
+		return nil
+	}
+	decoder, ok := decoderFor(req.ContentType())
+	if !ok {
+		return nil
+	}
+	body, err := req.GetRawData()
+	if err != nil || len(body) == 0 {
+		return err
+	}
+	return decoder(body, obj)
+}
+
+func decodeProtoBuf(body []byte, obj any) error {
+	message, ok := obj.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf decode: %T does not implement proto.Message", obj)
+	}
+	return proto.Unmarshal(body, message)
+}
+
+// 第一行为表头，按 csv tag（没有时用字段名）对应到结构体字段，obj 为结构体（指针）切片的指针
+func decodeCsv(body []byte, obj any) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("csv decode: obj must be a pointer to slice, got %T", obj)
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("csv decode: unsupported element type %s", elemType)
+	}
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil || len(records) == 0 {
+		return err
+	}
+	fieldsByName := map[string]csvField{}
+	for _, field := range csvFields(elemType) {
+		fieldsByName[field.name] = field
+	}
+	for _, record := range records[1:] {
+		elem := reflect.New(elemType).Elem()
+		for i, name := range records[0] {
+			field, ok := fieldsByName[strings.TrimSpace(name)]
+			if !ok || i >= len(record) {
+				continue
+			}
+			sf := elemType.FieldByIndex(field.index)
+			if err := setWithString(elem.FieldByIndex(field.index), record[i], sf.Tag.Get("time_format")); err != nil {
+				return fmt.Errorf("csv decode field %s: %w", sf.Name, err)
+			}
+		}
+		if isPtr {
+			elem = elem.Addr()
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+	return nil
+}
+
+// 表单参数，multipart 请求需要先解析 multipart 才能拿到普通字段
+func (req *ReqStruct) formValues() map[string][]string {
+	if strings.HasPrefix(req.ContentType(), "multipart/") {
+		req.parseMultipart(defaultMultipartMemory)
+		return req.request.PostForm
+	}
+	return req.FormAll()
+}
+
+// 从指定来源取值
+func (req *ReqStruct) bindValues(source, name string, query map[string][]string) ([]string, bool) {
+	switch source {
+	case "path":
+		val, ok := req.Param(name)
+		return []string{val}, ok
+	case "query":
+		vals, ok := query[name]
+		return vals, ok && len(vals) > 0
+	case "form":
+		vals, ok := req.formValues()[name]
+		return vals, ok && len(vals) > 0
+	case "header":
+		vals := req.request.Header.Values(name)
+		return vals, len(vals) > 0
+	case "cookie":
+		cookie, err := req.request.Cookie(name)
+		if err != nil {
+			return nil, false
+		}
+		return []string{cookie.Value}, true
+	}
+	return nil, false
+}
+
+// 遍历结构体字段赋值，返回是否有字段被赋值，用于决定是否保留嵌套结构体指针
+func (req *ReqStruct) bindStruct(v reflect.Value, query map[string][]string) (bool, error) {
+	t := v.Type()
+	bound := false
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		field := v.Field(i)
+		tagged := false
+		for _, source := range bindSources {
+			tag := sf.Tag.Get(source)
+			if tag == "" || tag == "-" {
+				continue
+			}
+			tagged = true
+			name := strings.Split(tag, ",")[0]
+			vals, ok := req.bindValues(source, name, query)
+			if !ok {
+				continue
+			}
+			if err := setFieldValues(field, vals, sf.Tag.Get("time_format")); err != nil {
+				return bound, fmt.Errorf("bind field %s: %w", sf.Name, err)
+			}
+			bound = true
+			break
+		}
+		if tagged || !isNestedStruct(sf.Type) {
+			continue
+		}
+		// 没有来源 tag 的嵌套结构体递归绑定
+		if field.Kind() == reflect.Ptr {
+			nested := reflect.New(sf.Type.Elem())
+			if field.IsNil() {
+				ok, err := req.bindStruct(nested.Elem(), query)
+				if err != nil {
+					return bound, err
+				}
+				if ok {
+					field.Set(nested)
+					bound = true
+				}
+				continue
+			}
+			field = field.Elem()
+		}
+		ok, err := req.bindStruct(field, query)
+		if err != nil {
+			return bound, err
+		}
+		bound = bound || ok
+	}
+	return bound, nil
+}
+
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	return !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// 多个值对应切片/数组字段，否则取第一个值
+func setFieldValues(field reflect.Value, vals []string, layout string) error {
+	switch field.Kind() {
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(vals[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setWithString(slice.Index(i), val, layout); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	case reflect.Array:
+		for i := 0; i < field.Len() && i < len(vals); i++ {
+			if err := setWithString(field.Index(i), vals[i], layout); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setWithString(field, vals[0], layout)
+}
+
+// 字符串转换为字段类型后赋值
+// layout 是 time.Time 的格式，默认 RFC3339，"unix" 表示时间戳（秒）
+func setWithString(field reflect.Value, val string, layout string) error {
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setWithString(ptr.Elem(), val, layout); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+	if field.Type() == timeType {
+		return setTimeField(field, val, layout)
+	}
+	if field.CanAddr() && field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+	if field.Type() == durationType {
+		if val == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(val)
+		return nil
+	case reflect.Interface:
+		if field.NumMethod() == 0 {
+			field.Set(reflect.ValueOf(val))
+			return nil
+		}
+	}
+	// 数值、布尔类型空字符串保持零值
+	if val == "" {
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(val, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Complex64, reflect.Complex128:
+		n, err := strconv.ParseComplex(val, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetComplex(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+func setTimeField(field reflect.Value, val string, layout string) error {
+	if val == "" {
+		return nil
+	}
+	if layout == "unix" {
+		sec, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(time.Unix(sec, 0)))
+		return nil
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	t, err := time.Parse(layout, val)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(t))
+	return nil
+}
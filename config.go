@@ -0,0 +1,86 @@
+// 读取 config.Service 中框架自身的配置项
+package httpserver
+
+import (
+	"github.com/spf13/cast"
+	"github.com/textthree/provider"
+	"github.com/textthree/provider/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config.Service 没有为框架配置项提供专门方法的，通过通用的 Get 读取
+type configGetter interface {
+	Get(key string) interface{}
+}
+
+// config.Service 没有 Get 方法时，http.bodyLimit、可信代理、TLS、超时、健康检查等配置项都读取不到，
+// 在创建 Engine 时提示一次，而不是静默使用默认值
+func checkConfigGetter(cfg config.Service) {
+	if _, ok := cfg.(configGetter); !ok {
+		provider.Clog().Error("[Config] config.Service does not implement Get(key string) interface{}, http.* settings are ignored")
+	}
+}
+
+func configValue(cfg config.Service, key string) (interface{}, bool) {
+	getter, ok := cfg.(configGetter)
+	if !ok {
+		return nil, false
+	}
+	val := getter.Get(key)
+	return val, val != nil
+}
+
+// 字节数配置，支持数字或 "512KB"、"10MB"、"1GB" 这样的写法，写错时提示并按未配置处理
+func configByteSize(cfg config.Service, key string) int64 {
+	val, ok := configValue(cfg, key)
+	if !ok {
+		return 0
+	}
+	size, err := parseByteSize(cast.ToString(val))
+	if err != nil {
+		provider.Clog().Error("[Config] invalid byte size "+key+", the setting is ignored", err)
+		return 0
+	}
+	return size
+}
+
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), 64)
+			if err != nil {
+				return 0, err
+			}
+			return int64(n * float64(unit.size)), nil
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// 时长配置，支持 "30s"、"1m" 这样的写法，纯数字按秒计算，写错时提示并按未配置处理
+func configDuration(cfg config.Service, key string) time.Duration {
+	val, ok := configValue(cfg, key)
+	if !ok {
+		return 0
+	}
+	str := strings.TrimSpace(cast.ToString(val))
+	if seconds, err := strconv.ParseFloat(str, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		provider.Clog().Error("[Config] invalid duration "+key+", the setting is ignored", err)
+		return 0
+	}
+	return d
+}
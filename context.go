@@ -3,12 +3,16 @@ package httpserver
 import (
 	"context"
 	"github.com/textthree/cvgokit/castkit"
+	"github.com/textthree/provider"
 	"github.com/textthree/provider/clog"
 	"github.com/textthree/provider/config"
 	"github.com/textthree/provider/core"
 	"github.com/textthree/provider/i18n"
+	"io"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,10 +33,21 @@ type Context struct {
 	writerMux *sync.Mutex
 	// 2. 添加标记，当发生 timeout 时设置标记位为 true，在 Context 提供的写 response 函数中，
 	//    先读取标记位，如果为 true，表示已经给客户端返回过了，就不要再写 response 了。
-	hasTimeout bool
+	hasTimeout atomic.Bool
+	// 超时后仍在运行的业务 Goroutine，请求结束时的清理要等它们结束
+	pending sync.WaitGroup
+	// 事件流等长连接响应，Timeout 中间件不会中断，在业务 Goroutine 中设置、Timeout 中读取
+	streaming atomic.Bool
 	// 服务中心
-	container core.Container
-	values    map[string]interface{}
+	container     core.Container
+	values        map[string]interface{}
+	errorHandler  ErrorHandler
+	rawBody       io.ReadCloser // 设置 body 大小限制前的原始 body
+	bodyLimit     int64
+	routePattern  string // 匹配到的路由，如 /user/:id
+	principal     *Principal
+	session       *Session
+	sessionConfig *SessionConfig
 
 	// 配置服务
 	Req    IRequest
@@ -44,18 +59,23 @@ type Context struct {
 
 func NewContext(r *http.Request, w http.ResponseWriter, holder core.Container) *Context {
 	req := &ReqStruct{request: r}
+	recorder := &responseRecorder{ResponseWriter: w}
 	ctx := &Context{
+		request:        r,
 		context:        r.Context(),
 		writerMux:      &sync.Mutex{},
 		middwaresIndex: -1,
 		container:      holder,
 		values:         map[string]interface{}{},
 		Req:            req,
-		Resp:           RespStruct{request: req, responseWriter: w},
+		Resp:           RespStruct{request: req, responseWriter: recorder, recorder: recorder},
 		Config:         holder.NewSingle(config.Name).(config.Service),
 		I18n:           holder.NewSingle(i18n.Name).(i18n.Service),
 		Log:            holder.NewSingle(clog.Name).(clog.Service),
 	}
+	ctx.Resp.writerMux = ctx.writerMux
+	ctx.Resp.hasTimeout = &ctx.hasTimeout
+	req.resetBody = ctx.ResetBody
 	return ctx
 }
 
@@ -95,17 +115,69 @@ func (ctx *Context) GetResponse() http.ResponseWriter {
 }
 
 func (ctx *Context) SetHasTimeout() {
-	ctx.hasTimeout = true
+	ctx.hasTimeout.Store(true)
 }
 
 func (ctx *Context) HasTimeout() bool {
-	return ctx.hasTimeout
+	return ctx.hasTimeout.Load()
+}
+
+// 标记超时并通过 write 写出超时响应，两步在同一次加锁中完成，之后业务 Goroutine 通过 Resp 的写入都会被丢弃。
+// 响应已经开始写出时状态码无法再修改，只标记超时
+func (ctx *Context) WriteTimeout(write func(resp IResponse)) {
+	ctx.writerMux.Lock()
+	defer ctx.writerMux.Unlock()
+	if ctx.hasTimeout.Swap(true) {
+		return
+	}
+	recorder := ctx.Resp.recorder
+	if recorder.status != 0 || ctx.Resp.wroteHeader {
+		return
+	}
+	// Resp 已经拒绝写入，用一个直接写底层 ResponseWriter 的 RespStruct 输出，状态码和字节数记回原来的 recorder
+	direct := &responseRecorder{ResponseWriter: recorder.ResponseWriter}
+	write(&RespStruct{request: ctx.Resp.request, responseWriter: direct, recorder: direct, writerMux: &sync.Mutex{}, hasTimeout: &atomic.Bool{}})
+	recorder.status, recorder.size = direct.status, direct.size
+}
+
+// 在新的 Goroutine 中执行 fn，请求结束时的 session 保存、上传临时文件清理等到 fn 返回后再执行，
+// 用于 Timeout 这类不等业务执行完就返回的中间件
+func (ctx *Context) Go(fn func()) {
+	ctx.pending.Add(1)
+	go func() {
+		defer ctx.pending.Done()
+		fn()
+	}()
+}
+
+// 请求结束：没有写出过响应时先保存 session，再写出只设置了状态码的响应，最后清理上传的临时文件。
+// 超时后业务 Goroutine 可能还在读取上传文件、修改 session，等它结束后在后台执行
+func (ctx *Context) finish() {
+	cleanup := func() {
+		ctx.commitSession()
+		ctx.Resp.writeHeader()
+		ctx.cleanupMultipart()
+	}
+	if ctx.HasTimeout() {
+		go func() {
+			ctx.pending.Wait()
+			cleanup()
+		}()
+		return
+	}
+	ctx.pending.Wait()
+	cleanup()
 }
 
 func (ctx *Context) BaseContext() context.Context {
 	return ctx.context
 }
 
+// 替换底层 context，如注入 trace span，之后把 Context 作为 context.Context 传给下游调用时生效
+func (ctx *Context) SetBaseContext(c context.Context) {
+	ctx.context = c
+}
+
 // #region implement context.Context
 func (ctx *Context) Deadline() (deadline time.Time, ok bool) {
 	return ctx.context.Deadline()
@@ -132,6 +204,150 @@ func (ctx *Context) NewInstanceProvider(name string, params ...interface{}) inte
 	return ctx.container.NewInstance(name, params)
 }
 
+// 将请求参数绑定到结构体，见 ReqStruct.Bind
+func (ctx *Context) Bind(obj any) error {
+	return ctx.Req.Bind(obj)
+}
+
+// 绑定后按 validate tag 校验，校验失败返回 ValidationErrors，提示信息经过 i18n 翻译
+//
+//	if err := ctx.BindAndValidate(dto); err != nil {
+//		ctx.Error(err)
+//		return
+//	}
+func (ctx *Context) BindAndValidate(obj any) error {
+	if err := ctx.Bind(obj); err != nil {
+		return err
+	}
+	err := Validate(obj)
+	if errs, ok := err.(ValidationErrors); ok {
+		return ctx.translateValidationErrors(errs)
+	}
+	return err
+}
+
+// 语言包中配置 validate.<rule> 即可翻译校验提示，支持 {field} {param} 占位符
+type validateTranslator interface {
+	T(key string) string
+}
+
+// i18n 服务不支持翻译时只提示一次
+var warnValidateTranslator sync.Once
+
+func (ctx *Context) translateValidationErrors(errs ValidationErrors) ValidationErrors {
+	translator, ok := ctx.I18n.(validateTranslator)
+	if !ok {
+		warnValidateTranslator.Do(func() {
+			provider.Clog().Error("[Validate] i18n.Service does not implement T(key string) string, validation messages are not translated")
+		})
+		return errs
+	}
+	for i, fieldErr := range errs {
+		key := "validate." + fieldErr.Rule
+		if tmpl := translator.T(key); tmpl != "" && tmpl != key {
+			errs[i].Message = formatValidateMessage(tmpl, fieldErr.Field, fieldErr.Param)
+		}
+	}
+	return errs
+}
+
+// 匹配到的路由，如 /user/:id，用于日志、监控按路由聚合，没有匹配到路由时为空
+func (ctx *Context) RoutePattern() string {
+	return ctx.routePattern
+}
+
+// 路由匹配到的路径参数
+func (ctx *Context) setParams(params map[string]string) {
+	if req, ok := ctx.Req.(*ReqStruct); ok {
+		req.params = params
+	}
+}
+
+// 限制请求 body 大小，Content-Length 超出时直接返回 ErrBodyTooLarge，
+// 否则读取 body 超出限制时返回 *http.MaxBytesError，默认错误处理函数都会输出 413。
+// 多次调用以最后一次为准，路由上的限制可以覆盖全局限制
+func (ctx *Context) SetBodyLimit(size int64) error {
+	ctx.limitBody(size)
+	return ctx.checkContentLength()
+}
+
+func (ctx *Context) limitBody(size int64) {
+	ctx.bodyLimit = size
+	if size <= 0 {
+		// 路由上设置为不限制时，去掉全局限制的包装
+		if ctx.rawBody != nil {
+			ctx.request.Body = ctx.rawBody
+		}
+		return
+	}
+	if ctx.request.Body == nil || ctx.request.Body == http.NoBody {
+		return
+	}
+	if ctx.rawBody == nil {
+		ctx.rawBody = ctx.request.Body
+	}
+	// 使用原始的 ResponseWriter，超出限制时 net/http 才能关闭连接
+	ctx.request.Body = http.MaxBytesReader(ctx.Resp.recorder.ResponseWriter, ctx.rawBody, size)
+}
+
+// 读取 body 后用读取出的内容重新填充，后续的读取和路由上的 BodyLimit 作用在新的 body 上
+func (ctx *Context) ResetBody(body io.Reader) {
+	ctx.rawBody = io.NopCloser(body)
+	ctx.request.Body = ctx.rawBody
+	ctx.limitBody(ctx.bodyLimit)
+}
+
+// 根据 Content-Length 提前拒绝，不用等到读取 body
+func (ctx *Context) checkContentLength() error {
+	if ctx.bodyLimit > 0 && ctx.request.ContentLength > ctx.bodyLimit {
+		return ErrBodyTooLarge
+	}
+	return nil
+}
+
+// Post* 方法读取 body 失败时控制器拿到的是空值，在控制器执行完后返回这个错误，
+// 控制器已经写出响应时只记录日志
+func (ctx *Context) postBodyError() error {
+	req, ok := ctx.Req.(*ReqStruct)
+	if !ok || req.postErr == nil {
+		return nil
+	}
+	if ctx.Resp.started() {
+		provider.Clog().Error("[Read post body fail]", req.postErr)
+		return nil
+	}
+	return req.postErr
+}
+
+func (ctx *Context) setMaxPostSize(size int64) {
+	if req, ok := ctx.Req.(*ReqStruct); ok {
+		req.maxPostSize = size
+	}
+}
+
+func (ctx *Context) setTrustedProxies(proxies []*net.IPNet) {
+	if req, ok := ctx.Req.(*ReqStruct); ok {
+		req.trustedProxies = proxies
+	}
+}
+
+// 客户端真实 IP、协议、域名，见 ReqStruct.ClientIp
+func (ctx *Context) ClientIp() string {
+	return ctx.Req.ClientIp()
+}
+
+func (ctx *Context) Scheme() string {
+	return ctx.Req.Scheme()
+}
+
+func (ctx *Context) Host() string {
+	return ctx.Req.Host()
+}
+
+func (ctx *Context) BaseURL() string {
+	return ctx.Req.BaseURL()
+}
+
 // 往 context 上设置值/获取值
 func (ctx *Context) SetVal(key string, value interface{}) {
 	ctx.values[key] = value
@@ -1,6 +1,8 @@
 package cvgohttp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/textthree/cvgoweb"
 	"github.com/textthree/provider"
@@ -9,15 +11,39 @@ import (
 	"github.com/textthree/provider/core"
 	"github.com/textthree/provider/core/types"
 	"github.com/textthree/provider/i18n"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 type HttpEngine func(engine *httpserver.Engine)
 
-func Run(router HttpEngine, addr ...string) {
-	ADDR := ""
+// 启动 http 服务，收到 SIGINT/SIGTERM 后优雅关闭：
+// 停止接收新连接，等待处理中的请求完成（最多 http.shutdownTimeout），
+// 再执行 engine.OnShutdown 注册的钩子（最多 http.shutdownHookTimeout）。
+// 正常关闭返回 nil，启动失败或关闭出错时返回错误
+func Run(router HttpEngine, addr ...string) error {
+	var opts []Option
 	if len(addr) > 0 {
-		ADDR = addr[0]
+		opts = append(opts, WithAddr(addr[0]))
+	}
+	return RunWithOptions(router, opts...)
+}
+
+// 在已打开的 listener 上启动服务，其他行为与 Run 相同
+func RunListener(router HttpEngine, listener net.Listener, opts ...Option) error {
+	return RunWithOptions(router, append([]Option{WithListener(listener)}, opts...)...)
+}
+
+// 与 Run 相同，通过 Option 设置监听地址、超时、连接数限制等，优先级高于配置文件
+func RunWithOptions(router HttpEngine, opts ...Option) error {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
 	}
 	// httpserver 使用单独的服务容器，不对包外暴露
 	c := core.NewContainer()
@@ -25,41 +51,263 @@ func Run(router HttpEngine, addr ...string) {
 	c.Bind(&clog.ClogProvider{})
 	c.Bind(&i18n.I18nProvider{})
 	c.Bind(&httpserver.HttpServerProvider{})
-	startHttpServer(c, ADDR, router)
+	for _, p := range o.providers {
+		c.Bind(p)
+	}
+	return startHttpServer(c, router, o)
 }
 
 // 启动 http 服务
-func startHttpServer(c *core.ServicesContainer, addr string, router HttpEngine) {
+func startHttpServer(c *core.ServicesContainer, router HttpEngine, o *options) error {
 	cfgsvc := c.NewSingle(config.Name).(config.Service)
-	engine := c.NewSingle(httpserver.Name).(*httpserver.HttpServerService).Engine.NewHttpEngine(c, cfgsvc)
+	svc := c.NewSingle(httpserver.Name).(*httpserver.HttpServerService)
+	engine := svc.Engine.NewHttpEngine(c, cfgsvc)
+	if err := engine.ConfigError(); err != nil {
+		return fmt.Errorf("load http config: %w", err)
+	}
+	// 其他服务从容器取到的 httpserver 服务使用同一个 engine，之前通过 svc 注册的生命周期对象此时注册到 engine
+	svc.SetEngine(engine)
+	// 容器中的服务实现了 StartAware、ReadyAware、ShutdownAware 的参与生命周期
+	engine.UseServices(config.Name, clog.Name, i18n.Name)
+	for _, p := range o.providers {
+		engine.UseServices(p.Name())
+	}
 	router(engine) // 把路由保存到 map
-
-	// 代码中没有传递端口则去配置文件找
-	if addr == "" {
-		addr = ":" + cfgsvc.GetHttpPort()
+	if err := runStartHooks(engine); err != nil {
+		return err
 	}
+	serverCfg := engine.ServerConfig()
+	for _, configure := range o.serverConfig {
+		configure(&serverCfg)
+	}
+	engine.SetServerConfig(serverCfg)
+
 	server := &http.Server{
 		// 自定义的请求核心处理函数
-		Handler: engine,
-		// 请求监听地址
-		Addr: addr,
+		Handler:           engine,
+		ReadTimeout:       serverCfg.ReadTimeout,
+		ReadHeaderTimeout: serverCfg.ReadHeaderTimeout,
+		WriteTimeout:      serverCfg.WriteTimeout,
+		IdleTimeout:       serverCfg.IdleTimeout,
+		MaxHeaderBytes:    serverCfg.MaxHeaderBytes,
+	}
+	reloader, err := configureProtocols(server, engine)
+	if err != nil {
+		return errors.Join(fmt.Errorf("configure http server: %w", err), runShutdownHooks(engine))
+	}
+	if reloader != nil {
+		defer reloader.Close()
+	}
+
+	listeners, handoff, err := openListeners(cfgsvc, serverCfg, o)
+	if err != nil {
+		return errors.Join(fmt.Errorf("start http server: %w", err), runShutdownHooks(engine))
 	}
-	httpServerOutput(cfgsvc, addr)
-	err := server.ListenAndServe() // 启动服务
+	// 同一个 server 服务所有 listener
+	servers := make([]serverListener, 0, len(listeners)+1)
+	for _, listener := range listeners {
+		servers = append(servers, serverListener{server: server, listener: engine.LimitListener(listener), tls: server.TLSConfig != nil})
+	}
+	if redirectAddr := engine.TLSConfig().RedirectAddr; redirectAddr != "" && server.TLSConfig != nil {
+		redirectServer := newRedirectServer(redirectAddr, httpsPort(listeners))
+		redirectServer.ReadHeaderTimeout = serverCfg.ReadHeaderTimeout
+		redirectListener, err := net.Listen("tcp", redirectAddr)
+		if err != nil {
+			closeListeners(listeners)
+			return errors.Join(fmt.Errorf("start https redirect server: %w", err), runShutdownHooks(engine))
+		}
+		servers = append(servers, serverListener{server: redirectServer, listener: redirectListener})
+	}
+	httpServerOutput(cfgsvc, listeners, server.TLSConfig != nil)
+	return serveUntilSignal(engine, handoff, servers...)
+}
+
+// 按 WithListener 传入的 listener、WithAddr、http.listen / WithListen 的顺序打开监听，都没有时监听配置文件中的端口
+// 由重启启动的进程直接使用旧进程移交的 listener。handoff 为框架打开的 listener，重启时移交给新进程
+func openListeners(cfgsvc config.Service, serverCfg httpserver.ServerConfig, o *options) (listeners, handoff []net.Listener, err error) {
+	inherited, err := inheritedListeners()
 	if err != nil {
+		return nil, nil, err
+	}
+	if len(inherited) > 0 {
+		return append(append([]net.Listener{}, o.listeners...), inherited...), inherited, nil
+	}
+	specs := serverCfg.Listen
+	if o.addr != "" {
+		specs = append([]string{o.addr}, specs...)
+	}
+	if len(specs) == 0 && len(o.listeners) == 0 {
+		// 代码中没有传递端口则去配置文件找
+		specs = []string{":" + cfgsvc.GetHttpPort()}
+	}
+	for _, spec := range specs {
+		ls, err := listen(spec)
+		if err != nil {
+			closeListeners(handoff)
+			return nil, nil, err
+		}
+		handoff = append(handoff, ls...)
+	}
+	return append(append([]net.Listener{}, o.listeners...), handoff...), handoff, nil
+}
+
+// 第一个 tcp listener 的端口，用于 http→https 跳转
+func httpsPort(listeners []net.Listener) string {
+	for _, l := range listeners {
+		if addr, ok := l.Addr().(*net.TCPAddr); ok {
+			return strconv.Itoa(addr.Port)
+		}
+	}
+	return ""
+}
+
+type serverListener struct {
+	server   *http.Server
+	listener net.Listener
+	tls      bool
+}
+
+// 是否 https 在启动前确定，同一个 server 服务多个 listener 时 Serve 可能会修改 server.TLSConfig
+func (self serverListener) serve() error {
+	if self.tls {
+		return self.server.ServeTLS(self.listener, "", "")
+	}
+	return self.server.Serve(self.listener)
+}
+
+// 启动服务并阻塞，直到任一服务出错或收到退出信号
+// 收到 SIGHUP / SIGUSR2 时把 handoff 中的 listener 移交给新进程，新进程就绪后当前进程优雅关闭
+func serveUntilSignal(engine *httpserver.Engine, handoff []net.Listener, servers ...serverListener) error {
+	serveErr := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server serverListener) {
+			serveErr <- server.serve() // 启动服务
+		}(server)
+	}
+	// 就绪钩子执行完才通知旧进程（重启时），失败则关闭服务
+	if err := runReadyHooks(engine); err != nil {
 		provider.Clog().Error("[Start http fail]", err)
+		return errors.Join(err, shutdown(engine, servers...))
+	}
+	engine.Health().SetReady(true)
+	notifyReady()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	restart := make(chan os.Signal, 1)
+	if len(restartSignals) > 0 {
+		signal.Notify(restart, restartSignals...)
+		defer signal.Stop(restart)
 	}
+
+	var startErr error
+	for running := true; running; {
+		select {
+		case err := <-serveErr:
+			if !errors.Is(err, http.ErrServerClosed) {
+				provider.Clog().Error("[Start http fail]", err)
+				startErr = fmt.Errorf("start http server: %w", err)
+			}
+			running = false
+		case sig := <-quit:
+			provider.Clog().Trace("Shutdown http server, signal:", sig.String())
+			// 先让 /readyz 返回 503，等负载均衡摘除实例后再停止接收新连接
+			engine.Health().SetReady(false)
+			time.Sleep(engine.Health().ShutdownDelay())
+			running = false
+		case sig := <-restart:
+			provider.Clog().Trace("Restart http server, signal:", sig.String())
+			if err := forkExec(handoff); err != nil {
+				// 新进程没有就绪，继续由当前进程提供服务
+				provider.Clog().Error("[Restart http fail]", err)
+				continue
+			}
+			running = false
+		}
+	}
+	return errors.Join(startErr, shutdown(engine, servers...))
 }
 
-func httpServerOutput(cfgsvc config.Service, addr string) {
+// 停止接收新连接并等待处理中的请求完成，超时后强制关闭剩余连接，最后执行关闭钩子。
+// 关闭钩子使用单独的超时，不会因为等待请求耗尽了时间而拿到已经结束的 ctx
+func shutdown(engine *httpserver.Engine, servers ...serverListener) error {
+	engine.Health().SetReady(false)
+	ctx, cancel := context.WithTimeout(context.Background(), engine.ShutdownTimeout())
+	defer cancel()
+	var errs []error
+	closed := map[*http.Server]bool{}
+	for _, item := range servers {
+		server := item.server
+		if closed[server] {
+			continue
+		}
+		closed[server] = true
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown http server: %w", err))
+			server.Close()
+		}
+	}
+	cancel()
+	if err := runShutdownHooks(engine); err != nil {
+		errs = append(errs, err)
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		provider.Clog().Error("[Shutdown http fail]", err)
+	}
+	return err
+}
+
+// 执行启动钩子，失败时执行已注册的关闭钩子释放资源
+func runStartHooks(engine *httpserver.Engine) error {
+	ctx, cancel := context.WithTimeout(context.Background(), engine.StartTimeout())
+	defer cancel()
+	if err := engine.RunStartHooks(ctx); err != nil {
+		provider.Clog().Error("[Start http fail]", err)
+		return errors.Join(fmt.Errorf("start http server: %w", err), runShutdownHooks(engine))
+	}
+	return nil
+}
+
+func runReadyHooks(engine *httpserver.Engine) error {
+	ctx, cancel := context.WithTimeout(context.Background(), engine.StartTimeout())
+	defer cancel()
+	if err := engine.RunReadyHooks(ctx); err != nil {
+		return fmt.Errorf("start http server: %w", err)
+	}
+	return nil
+}
+
+// 服务没有启动成功时执行关闭钩子
+func runShutdownHooks(engine *httpserver.Engine) error {
+	ctx, cancel := context.WithTimeout(context.Background(), engine.ShutdownHookTimeout())
+	defer cancel()
+	if err := engine.RunShutdownHooks(ctx); err != nil {
+		return fmt.Errorf("shutdown hooks: %w", err)
+	}
+	return nil
+}
+
+func httpServerOutput(cfgsvc config.Service, listeners []net.Listener, https bool) {
 	// web server
-	info := fmt.Sprintf("\033[36m%s"+"\033[0m", "WebServer: http://localhost"+addr)
-	fmt.Println(info)
+	scheme := "http"
+	if https {
+		scheme = "https"
+	}
+	for _, l := range listeners {
+		addr := listenerAddr(l)
+		if tcpAddr, ok := l.Addr().(*net.TCPAddr); ok {
+			addr = scheme + "://localhost:" + strconv.Itoa(tcpAddr.Port)
+		}
+		info := fmt.Sprintf("\033[36m%s"+"\033[0m", "WebServer: "+addr)
+		fmt.Println(info)
+	}
 	// swager server
 	swagCfg := cfgsvc.GetSwagger()
 	if swagCfg != (types.SwaggerConfig{}) {
 		str := "SwaggerUI: http://localhost:" + cfgsvc.GetHttpPort() + "/swagger-ui/index.html\n"
-		info = fmt.Sprintf("\033[36m%s"+"\033[0m", str)
+		info := fmt.Sprintf("\033[36m%s"+"\033[0m", str)
 		fmt.Println(info)
 	}
 }
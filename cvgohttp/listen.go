@@ -0,0 +1,196 @@
+// 监听地址解析：tcp、unix socket、继承的文件描述符（systemd socket activation）
+package cvgohttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// systemd 传递的第一个文件描述符
+const listenFdsStart = 3
+
+// 根据监听地址创建 listener，支持的写法：
+//
+//	":8080"、"127.0.0.1:8080"、"tcp://:8080"、"tcp6://[::1]:8080"
+//	"unix:///run/app.sock"、"unix:///run/app.sock?mode=0660"
+//	"fd://3"                 继承的文件描述符
+//	"systemd://"、"systemd://web"  systemd socket activation 的全部 / 指定名称（FileDescriptorName）的 socket
+func listen(spec string) ([]net.Listener, error) {
+	if !strings.Contains(spec, "://") {
+		l, err := net.Listen("tcp", spec)
+		return wrapListener(l, err)
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen spec %q: %w", spec, err)
+	}
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6":
+		l, err := net.Listen(u.Scheme, u.Host)
+		return wrapListener(l, err)
+	case "unix":
+		l, err := listenUnix(u.Host+u.Path, u.Query().Get("mode"))
+		return wrapListener(l, err)
+	case "fd":
+		fd, err := strconv.Atoi(u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listen spec %q: %w", spec, err)
+		}
+		l, err := fileListener(uintptr(fd), spec)
+		return wrapListener(l, err)
+	case "systemd":
+		return systemdListeners(u.Host)
+	}
+	return nil, fmt.Errorf("unsupported listen spec %q", spec)
+}
+
+func wrapListener(l net.Listener, err error) ([]net.Listener, error) {
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{l}, nil
+}
+
+// 监听 unix socket，mode 为八进制权限如 "0660"，为空时使用进程 umask
+func listenUnix(path string, mode string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("unix socket path is required")
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != "" {
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("invalid unix socket mode %q: %w", mode, err)
+		}
+		if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// 进程异常退出后 socket 文件会残留，导致 bind 失败
+// 连不上说明没有进程在监听，删除残留文件；能连上说明地址仍被占用，返回错误
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("unix socket %s is already in use", path)
+	}
+	return os.Remove(path)
+}
+
+func fileListener(fd uintptr, name string) (net.Listener, error) {
+	file := os.NewFile(fd, name)
+	if file == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	// net.FileListener 会 dup 一份描述符，原文件可以关闭
+	defer file.Close()
+	l, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("listen on file descriptor %d: %w", fd, err)
+	}
+	return l, nil
+}
+
+type inheritedFd struct {
+	fd   uintptr
+	name string
+}
+
+var (
+	systemdFdsOnce sync.Once
+	systemdFds     []inheritedFd
+)
+
+// 读取 LISTEN_PID、LISTEN_FDS、LISTEN_FDNAMES，只读取一次并清除环境变量，避免子进程误用
+func inheritedSystemdFds() []inheritedFd {
+	systemdFdsOnce.Do(func() {
+		defer func() {
+			os.Unsetenv("LISTEN_PID")
+			os.Unsetenv("LISTEN_FDS")
+			os.Unsetenv("LISTEN_FDNAMES")
+		}()
+		pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		if err != nil || pid != os.Getpid() {
+			return
+		}
+		count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || count <= 0 {
+			return
+		}
+		names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+		for i := 0; i < count; i++ {
+			fd := uintptr(listenFdsStart + i)
+			item := inheritedFd{fd: fd, name: "LISTEN_FD_" + strconv.Itoa(int(fd))}
+			if i < len(names) && names[i] != "" {
+				item.name = names[i]
+			}
+			systemdFds = append(systemdFds, item)
+		}
+	})
+	return systemdFds
+}
+
+// systemd 传递的 socket，name 为空时返回全部
+func systemdListeners(name string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, item := range inheritedSystemdFds() {
+		if name != "" && item.name != name {
+			continue
+		}
+		l, err := fileListener(item.fd, item.name)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	if len(listeners) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("no systemd socket named %q", name)
+		}
+		return nil, errors.New("no systemd socket passed (LISTEN_FDS)")
+	}
+	return listeners, nil
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
+
+// 用于启动信息输出
+func listenerAddr(l net.Listener) string {
+	addr := l.Addr()
+	if addr.Network() == "unix" {
+		return "unix:" + addr.String()
+	}
+	return addr.String()
+}
@@ -0,0 +1,96 @@
+package cvgohttp
+
+import (
+	"github.com/textthree/cvgoweb"
+	"github.com/textthree/provider/core"
+	"net"
+	"time"
+)
+
+// RunWithOptions 的启动参数
+type Option func(o *options)
+
+type options struct {
+	addr         string
+	listeners    []net.Listener
+	providers    []core.ServiceProvider
+	serverConfig []func(cfg *httpserver.ServerConfig)
+}
+
+// 监听地址，如 ":8080"，不设置时使用配置文件中的端口
+func WithAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// 追加监听地址，可以多次调用，写法见 listen，如 "unix:///run/app.sock?mode=0660"、"systemd://"
+func WithListen(specs ...string) Option {
+	return withServerConfig(func(cfg *httpserver.ServerConfig) {
+		cfg.Listen = append(cfg.Listen, specs...)
+	})
+}
+
+// 使用已打开的 listener，服务关闭时由框架关闭
+func WithListener(listeners ...net.Listener) Option {
+	return func(o *options) {
+		o.listeners = append(o.listeners, listeners...)
+	}
+}
+
+// 把服务绑定到 Run 使用的服务容器，服务实现了 StartAware、ReadyAware、ShutdownAware 的参与生命周期
+func WithProviders(providers ...core.ServiceProvider) Option {
+	return func(o *options) {
+		o.providers = append(o.providers, providers...)
+	}
+}
+
+// 整体替换 http.Server 配置
+func WithServerConfig(cfg httpserver.ServerConfig) Option {
+	return withServerConfig(func(c *httpserver.ServerConfig) {
+		*c = cfg
+	})
+}
+
+func WithReadTimeout(timeout time.Duration) Option {
+	return withServerConfig(func(cfg *httpserver.ServerConfig) {
+		cfg.ReadTimeout = timeout
+	})
+}
+
+func WithReadHeaderTimeout(timeout time.Duration) Option {
+	return withServerConfig(func(cfg *httpserver.ServerConfig) {
+		cfg.ReadHeaderTimeout = timeout
+	})
+}
+
+func WithWriteTimeout(timeout time.Duration) Option {
+	return withServerConfig(func(cfg *httpserver.ServerConfig) {
+		cfg.WriteTimeout = timeout
+	})
+}
+
+func WithIdleTimeout(timeout time.Duration) Option {
+	return withServerConfig(func(cfg *httpserver.ServerConfig) {
+		cfg.IdleTimeout = timeout
+	})
+}
+
+func WithMaxHeaderBytes(size int) Option {
+	return withServerConfig(func(cfg *httpserver.ServerConfig) {
+		cfg.MaxHeaderBytes = size
+	})
+}
+
+// 最大并发连接数，超出时直接关闭新连接，被拒绝的连接数见 engine.ConnStats()
+func WithMaxConnections(max int) Option {
+	return withServerConfig(func(cfg *httpserver.ServerConfig) {
+		cfg.MaxConnections = max
+	})
+}
+
+func withServerConfig(configure func(cfg *httpserver.ServerConfig)) Option {
+	return func(o *options) {
+		o.serverConfig = append(o.serverConfig, configure)
+	}
+}
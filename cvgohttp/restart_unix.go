@@ -0,0 +1,189 @@
+//go:build !windows
+
+// 零停机重启：收到 SIGHUP / SIGUSR2 时 fork-exec 新的可执行文件，通过继承的文件描述符移交监听 socket，
+// 新进程就绪后旧进程优雅关闭
+package cvgohttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	envRestartFds     = "CVGO_RESTART_FDS"      // 继承的 listener 数量，从 fd 3 开始
+	envRestartReadyFd = "CVGO_RESTART_READY_FD" // 新进程就绪后写入该 fd 通知旧进程
+)
+
+// 等待新进程就绪的最长时间
+const restartReadyTimeout = time.Minute
+
+var restartSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
+
+var (
+	inheritedOnce sync.Once
+	inherited     []net.Listener
+	inheritedErr  error
+)
+
+// 重启时从旧进程继承的 listener，只读取一次并清除环境变量，避免再传给下一个子进程
+func inheritedListeners() ([]net.Listener, error) {
+	inheritedOnce.Do(func() {
+		val := os.Getenv(envRestartFds)
+		os.Unsetenv(envRestartFds)
+		if val == "" {
+			return
+		}
+		count, err := strconv.Atoi(val)
+		if err != nil {
+			inheritedErr = fmt.Errorf("invalid %s: %w", envRestartFds, err)
+			return
+		}
+		for i := 0; i < count; i++ {
+			l, err := fileListener(uintptr(listenFdsStart+i), "restart")
+			if err != nil {
+				closeListeners(inherited)
+				inherited, inheritedErr = nil, err
+				return
+			}
+			// 由文件描述符创建的 unix listener 默认关闭时不删除 socket 文件，改为和自己创建的一样
+			if listener, ok := l.(*net.UnixListener); ok {
+				listener.SetUnlinkOnClose(true)
+			}
+			inherited = append(inherited, l)
+		}
+	})
+	return inherited, inheritedErr
+}
+
+// 通知旧进程已就绪，不是由重启启动的进程什么也不做
+func notifyReady() {
+	val := os.Getenv(envRestartReadyFd)
+	os.Unsetenv(envRestartReadyFd)
+	fd, err := strconv.Atoi(val)
+	if err != nil {
+		return
+	}
+	file := os.NewFile(uintptr(fd), "ready")
+	if file == nil {
+		return
+	}
+	file.Write([]byte{1})
+	file.Close()
+}
+
+// 启动新进程并移交 listener，新进程就绪后返回 nil，之后由调用方关闭当前进程的服务
+// 新进程启动失败或超时未就绪时返回错误，当前进程继续提供服务
+func forkExec(listeners []net.Listener) (err error) {
+	if len(listeners) == 0 {
+		return errors.New("no listener to hand over")
+	}
+	defer func() {
+		// 移交失败，当前进程关闭时仍然删除 unix socket 文件
+		if err != nil {
+			for _, l := range listeners {
+				if listener, ok := l.(*net.UnixListener); ok {
+					listener.SetUnlinkOnClose(true)
+				}
+			}
+		}
+	}()
+	files := make([]*os.File, 0, len(listeners)+1)
+	closeFiles := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+	for _, l := range listeners {
+		file, err := listenerFile(l)
+		if err != nil {
+			closeFiles()
+			return err
+		}
+		files = append(files, file)
+	}
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		closeFiles()
+		return err
+	}
+	defer readyReader.Close()
+	files = append(files, readyWriter)
+
+	exe, err := os.Executable()
+	if err != nil {
+		closeFiles()
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(restartEnv(),
+		envRestartFds+"="+strconv.Itoa(len(listeners)),
+		envRestartReadyFd+"="+strconv.Itoa(listenFdsStart+len(listeners)),
+	)
+	err = cmd.Start()
+	// 子进程已经持有描述符，当前进程的副本可以关闭
+	closeFiles()
+	if err != nil {
+		return fmt.Errorf("start new process: %w", err)
+	}
+
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := readyReader.Read(buf)
+		ready <- err
+	}()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			cmd.Process.Kill()
+			return fmt.Errorf("new process %d exited before ready: %w", cmd.Process.Pid, err)
+		}
+		return nil
+	case err := <-exited:
+		return fmt.Errorf("new process %d exited before ready: %v", cmd.Process.Pid, err)
+	case <-time.After(restartReadyTimeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("new process %d not ready in %s", cmd.Process.Pid, restartReadyTimeout)
+	}
+}
+
+// 复制 listener 的描述符，unix socket 关闭时不再删除 socket 文件，由新进程继续使用
+func listenerFile(l net.Listener) (*os.File, error) {
+	switch listener := l.(type) {
+	case *net.TCPListener:
+		return listener.File()
+	case *net.UnixListener:
+		listener.SetUnlinkOnClose(false)
+		return listener.File()
+	}
+	return nil, fmt.Errorf("listener %s cannot be handed over", l.Addr())
+}
+
+// 去掉 systemd 和上一次重启的环境变量
+func restartEnv() []string {
+	env := make([]string, 0, len(os.Environ()))
+	for _, item := range os.Environ() {
+		name, _, _ := strings.Cut(item, "=")
+		switch name {
+		case envRestartFds, envRestartReadyFd, "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES":
+			continue
+		}
+		env = append(env, item)
+	}
+	return env
+}
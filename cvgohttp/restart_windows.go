@@ -0,0 +1,22 @@
+//go:build windows
+
+// windows 不支持通过继承文件描述符移交 listener，不提供零停机重启
+package cvgohttp
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+var restartSignals []os.Signal
+
+func inheritedListeners() ([]net.Listener, error) {
+	return nil, nil
+}
+
+func notifyReady() {}
+
+func forkExec(listeners []net.Listener) error {
+	return errors.New("restart is not supported on windows")
+}
@@ -0,0 +1,64 @@
+// https、HTTP/2、h2c 与 http→https 跳转
+package cvgohttp
+
+import (
+	"crypto/tls"
+	"github.com/textthree/cvgoweb"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
+	"net/http"
+)
+
+// 根据 engine 的 TLS、HTTP/2 配置设置 server，返回的 CertReloader 在关闭服务时停止
+func configureProtocols(server *http.Server, engine *httpserver.Engine) (*httpserver.CertReloader, error) {
+	tlsCfg := engine.TLSConfig()
+	http2Cfg := engine.HTTP2Config()
+	h2s := &http2.Server{
+		MaxConcurrentStreams: http2Cfg.MaxConcurrentStreams,
+		MaxReadFrameSize:     http2Cfg.MaxReadFrameSize,
+		IdleTimeout:          http2Cfg.IdleTimeout,
+	}
+
+	if !tlsCfg.Enabled() {
+		// 明文 HTTP/2，只在显式开启时使用
+		if http2Cfg.H2C && !http2Cfg.Disable {
+			server.Handler = h2c.NewHandler(server.Handler, h2s)
+		}
+		return nil, nil
+	}
+
+	tlsConfig, reloader, err := tlsCfg.Build()
+	if err != nil {
+		return nil, err
+	}
+	server.TLSConfig = tlsConfig
+	if http2Cfg.Disable {
+		// TLSNextProto 不为 nil 时 net/http 不会自动启用 HTTP/2
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		return reloader, nil
+	}
+	if err := http2.ConfigureServer(server, h2s); err != nil {
+		reloader.Close()
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// 把 http 请求 301 到 https，httpsPort 为空或 443 时跳转地址不带端口
+func newRedirectServer(addr string, httpsPort string) *http.Server {
+	return &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+			if httpsPort != "" && httpsPort != "443" {
+				host = net.JoinHostPort(host, httpsPort)
+			}
+			target := "https://" + host + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		}),
+	}
+}
@@ -0,0 +1,72 @@
+// 中间件、控制器返回错误时的统一处理
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 带 http 状态码的错误，默认错误处理函数按 Code 输出状态码
+type HTTPError struct {
+	Code    int
+	Message string
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// message 为空时使用状态码对应的标准描述
+func NewHTTPError(code int, message ...string) *HTTPError {
+	msg := http.StatusText(code)
+	if len(message) > 0 {
+		msg = message[0]
+	}
+	return &HTTPError{Code: code, Message: msg}
+}
+
+var ErrBodyTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge)
+
+// 错误处理函数，可通过 engine.SetErrorHandler 替换
+type ErrorHandler func(c *Context, err error)
+
+// 默认错误处理：
+// 参数校验失败返回 422 和字段错误列表，HTTPError 按其状态码返回，body 超出限制返回 413，其他错误返回 500
+func DefaultErrorHandler(c *Context, err error) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		c.Resp.SetStatus(httpErr.Code).Text(httpErr.Message)
+		return
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.Resp.SetStatus(ErrBodyTooLarge.Code).Text(ErrBodyTooLarge.Message)
+		return
+	}
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		c.Resp.SetStatus(http.StatusUnprocessableEntity).Json(map[string]interface{}{
+			"msg":    validationErrs.Error(),
+			"errors": validationErrs,
+		})
+		return
+	}
+	c.Resp.SetStatus(http.StatusInternalServerError).Text(err.Error())
+}
+
+// 替换默认错误处理函数
+func (self *Engine) SetErrorHandler(handler ErrorHandler) {
+	self.errorHandler = handler
+}
+
+// 将错误交给错误处理函数输出给客户端，控制器中出错时调用 ctx.Error(err) 后 return
+func (ctx *Context) Error(err error) {
+	if err == nil {
+		return
+	}
+	if ctx.errorHandler != nil {
+		ctx.errorHandler(ctx, err)
+		return
+	}
+	DefaultErrorHandler(ctx, err)
+}
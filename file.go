@@ -0,0 +1,179 @@
+// 文件响应：下载、内联展示、Range 断点续传、协商缓存
+package httpserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/textthree/provider"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 输出文件，支持 Range、If-Modified-Since、If-None-Match，path 需要调用方确保可信，不要直接拼接用户输入
+func (res *RespStruct) File(filePath string) IResponse {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return res.fileError(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return res.fileError(err)
+	}
+	if info.IsDir() {
+		return res.fileError(fs.ErrNotExist)
+	}
+	return res.serveFile(info, f)
+}
+
+// 作为附件下载，filename 为空时使用文件本身的名称
+func (res *RespStruct) Attachment(filePath string, filename string) IResponse {
+	if filename == "" {
+		filename = filepath.Base(filePath)
+	}
+	res.setHeader("Content-Disposition", contentDisposition("attachment", filename))
+	return res.File(filePath)
+}
+
+// 在浏览器中直接展示，filename 用于浏览器另存为时的默认文件名
+func (res *RespStruct) Inline(filePath string, filename string) IResponse {
+	if filename == "" {
+		filename = filepath.Base(filePath)
+	}
+	res.setHeader("Content-Disposition", contentDisposition("inline", filename))
+	return res.File(filePath)
+}
+
+// 输出 fs.FS 中的文件，如 embed.FS 嵌入的静态资源
+func (res *RespStruct) FileFS(fsys fs.FS, name string) IResponse {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	f, err := fsys.Open(name)
+	if err != nil {
+		return res.fileError(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return res.fileError(err)
+	}
+	if info.IsDir() {
+		return res.fileError(fs.ErrNotExist)
+	}
+	if seeker, ok := f.(io.ReadSeeker); ok {
+		return res.serveFile(info, seeker)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return res.fileError(err)
+	}
+	return res.serveFile(info, bytes.NewReader(data))
+}
+
+// 输出任意可 Seek 的内容，name 用于推断 Content-Type，modtime 为零值时不处理 If-Modified-Since
+func (res *RespStruct) ServeContent(name string, modtime time.Time, content io.ReadSeeker) IResponse {
+	if !res.lock() {
+		return res
+	}
+	defer res.unlock()
+	return res.serveContent(name, modtime, content)
+}
+
+// http.ServeContent 会直接修改 header 并写出，整个过程持有写锁
+func (res *RespStruct) serveContent(name string, modtime time.Time, content io.ReadSeeker) IResponse {
+	res.wroteHeader = true
+	http.ServeContent(res.responseWriter, res.request.request, name, modtime, content)
+	return res
+}
+
+// 流式输出，不支持 Range，适合边生成边输出的内容
+func (res *RespStruct) Stream(reader io.Reader, contentType string) IResponse {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	res.setHeader("Content-Type", contentType)
+	res.writeHeader()
+	buf := make([]byte, 32*1024)
+	flusher, _ := res.responseWriter.(http.Flusher)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			// 每一块单独加锁，超时后停止输出
+			if !res.lock() {
+				return res
+			}
+			_, werr := res.write(buf[:n])
+			if werr == nil && flusher != nil {
+				flusher.Flush()
+			}
+			res.unlock()
+			if werr != nil {
+				return res
+			}
+		}
+		if err != nil {
+			return res
+		}
+	}
+}
+
+// 根据文件大小和修改时间生成弱 ETag，配合 If-None-Match 使用
+// embed.FS 中的文件没有修改时间，不生成 ETag
+func (res *RespStruct) serveFile(info fs.FileInfo, content io.ReadSeeker) IResponse {
+	if !res.lock() {
+		return res
+	}
+	defer res.unlock()
+	header := res.responseWriter.Header()
+	if header.Get("ETag") == "" && !info.ModTime().IsZero() {
+		header.Set("ETag", fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano()))
+	}
+	return res.serveContent(info.Name(), info.ModTime(), content)
+}
+
+func (res *RespStruct) setHeader(key, val string) {
+	if !res.lock() {
+		return
+	}
+	defer res.unlock()
+	res.responseWriter.Header().Set(key, val)
+}
+
+func (res *RespStruct) fileError(err error) IResponse {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return res.SetStatus(http.StatusNotFound).Text("404 not found")
+	case errors.Is(err, fs.ErrPermission):
+		return res.SetStatus(http.StatusForbidden).Text("403 forbidden")
+	}
+	// 错误信息中带有服务器上的文件路径，只记录日志
+	provider.Clog().Error("[Serve file fail]", err)
+	return res.SetStatus(http.StatusInternalServerError).Text(http.StatusText(http.StatusInternalServerError))
+}
+
+// RFC 6266：filename 给不支持 RFC 5987 的客户端使用 ASCII 兜底，filename* 使用 UTF-8 编码原始文件名
+func contentDisposition(dispositionType, filename string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, filename)
+	disposition := mime.FormatMediaType(dispositionType, map[string]string{"filename": fallback})
+	if disposition == "" {
+		disposition = dispositionType
+	}
+	if fallback != filename {
+		disposition += "; filename*=UTF-8''" + strings.ReplaceAll(url.QueryEscape(filename), "+", "%20")
+	}
+	return disposition
+}
@@ -0,0 +1,323 @@
+// 健康检查：存活（liveness）、就绪（readiness）探针与详细报告
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/textthree/provider/config"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 健康检查函数，返回 nil 表示健康
+type HealthCheck func(ctx context.Context) error
+
+// 单个检查的配置
+type HealthCheckOptions struct {
+	// 超时，0 表示默认 5s
+	Timeout time.Duration
+	// 关键检查失败时 /readyz 返回 503，非关键检查失败只在报告中标记为 degraded
+	Critical bool
+	// 同时作为存活检查，失败时 /healthz 返回 503。只有进程自身无法恢复的问题才应该放到存活检查中
+	Liveness bool
+}
+
+// 健康检查配置
+type HealthConfig struct {
+	LivenessPath  string // 默认 /healthz
+	ReadinessPath string // 默认 /readyz
+	ReportPath    string // 详细 JSON 报告，默认 /health
+	// 检查结果缓存时间，避免探针频繁访问依赖，0 表示默认 1s，小于 0 表示不缓存
+	CacheInterval time.Duration
+	// 优雅关闭时 /readyz 先返回 503，等待该时长让负载均衡摘除实例后再停止接收新连接
+	ShutdownDelay time.Duration
+}
+
+const (
+	defaultHealthCheckTimeout  = 5 * time.Second
+	defaultHealthCacheInterval = time.Second
+)
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+	HealthStatusFail     = "fail"
+)
+
+// 健康报告
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Ready  bool                         `json:"ready"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+type HealthCheckResult struct {
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Liveness  bool      `json:"liveness"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+type Health struct {
+	config HealthConfig
+	mu     sync.RWMutex
+	checks []*healthCheck
+	ready  atomic.Bool
+}
+
+type healthCheck struct {
+	name    string
+	check   HealthCheck
+	options HealthCheckOptions
+	mu      sync.Mutex // 同一时间只执行一次，并发的探针等待同一个结果
+	result  HealthCheckResult
+}
+
+// 启用健康检查接口，这些接口在路由和中间件之前处理，不受全局中间件（如鉴权）影响。
+// 传入的配置只覆盖其中的非零字段，其余保留配置文件中的值
+func (self *Engine) EnableHealth(cfg ...HealthConfig) *Health {
+	if len(cfg) > 0 {
+		self.health.config.merge(cfg[0])
+	}
+	self.health.enabled = true
+	return self.health.Health
+}
+
+func (self *HealthConfig) merge(cfg HealthConfig) {
+	if cfg.LivenessPath != "" {
+		self.LivenessPath = cfg.LivenessPath
+	}
+	if cfg.ReadinessPath != "" {
+		self.ReadinessPath = cfg.ReadinessPath
+	}
+	if cfg.ReportPath != "" {
+		self.ReportPath = cfg.ReportPath
+	}
+	if cfg.CacheInterval != 0 {
+		self.CacheInterval = cfg.CacheInterval
+	}
+	if cfg.ShutdownDelay != 0 {
+		self.ShutdownDelay = cfg.ShutdownDelay
+	}
+}
+
+// 健康检查，未调用 EnableHealth 时也可以注册检查，只是不对外提供接口
+func (self *Engine) Health() *Health {
+	return self.health.Health
+}
+
+// engine 中的健康检查状态
+type engineHealth struct {
+	*Health
+	enabled bool
+}
+
+func newHealth(cfg config.Service) engineHealth {
+	health := &Health{config: HealthConfig{
+		CacheInterval: configDuration(cfg, "http.health.cacheInterval"),
+		ShutdownDelay: configDuration(cfg, "http.health.shutdownDelay"),
+	}}
+	enabled := false
+	if val, ok := configValue(cfg, "http.health.enable"); ok {
+		enabled = cast.ToBool(val)
+	}
+	return engineHealth{Health: health, enabled: enabled}
+}
+
+// 注册检查，同名检查会被替换
+func (self *Health) AddCheck(name string, check HealthCheck, options ...HealthCheckOptions) {
+	item := &healthCheck{name: name, check: check}
+	if len(options) > 0 {
+		item.options = options[0]
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for i, c := range self.checks {
+		if c.name == name {
+			self.checks[i] = item
+			return
+		}
+	}
+	self.checks = append(self.checks, item)
+}
+
+// 标记是否就绪，Run 在就绪钩子执行完后设为 true，开始优雅关闭时设为 false
+// 不使用 cvgohttp.Run 启动时需要自己调用
+func (self *Health) SetReady(ready bool) {
+	self.ready.Store(ready)
+}
+
+func (self *Health) Ready() bool {
+	return self.ready.Load()
+}
+
+// 优雅关闭前等待负载均衡摘除实例的时长
+func (self *Health) ShutdownDelay() time.Duration {
+	return self.config.ShutdownDelay
+}
+
+// 执行所有检查（使用缓存）生成报告
+func (self *Health) Report(ctx context.Context) HealthReport {
+	return self.report(ctx, false)
+}
+
+// livenessOnly 为 true 时只执行存活检查
+func (self *Health) report(ctx context.Context, livenessOnly bool) HealthReport {
+	self.mu.RLock()
+	checks := make([]*healthCheck, 0, len(self.checks))
+	for _, check := range self.checks {
+		if !livenessOnly || check.options.Liveness {
+			checks = append(checks, check)
+		}
+	}
+	self.mu.RUnlock()
+
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *healthCheck) {
+			defer wg.Done()
+			results[i] = check.run(ctx, self.cacheInterval())
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthStatusOK, Ready: self.Ready(), Checks: map[string]HealthCheckResult{}}
+	for i, check := range checks {
+		result := results[i]
+		report.Checks[check.name] = result
+		if result.Status == HealthStatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = HealthStatusFail
+		} else if report.Status == HealthStatusOK {
+			report.Status = HealthStatusDegraded
+		}
+	}
+	return report
+}
+
+func (self *Health) cacheInterval() time.Duration {
+	if self.config.CacheInterval == 0 {
+		return defaultHealthCacheInterval
+	}
+	return self.config.CacheInterval
+}
+
+func (self *healthCheck) run(ctx context.Context, cacheInterval time.Duration) HealthCheckResult {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if cacheInterval > 0 && !self.result.CheckedAt.IsZero() && time.Since(self.result.CheckedAt) < cacheInterval {
+		return self.result
+	}
+	timeout := self.options.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	// 结果会缓存给其他探针使用，不能因为触发检查的客户端断开而失败，只受检查超时限制
+	if cacheInterval > 0 {
+		ctx = context.WithoutCancel(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := self.safeCheck(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	self.result = HealthCheckResult{
+		Status:    HealthStatusOK,
+		Critical:  self.options.Critical,
+		Liveness:  self.options.Liveness,
+		Duration:  time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		self.result.Status = HealthStatusFail
+		self.result.Error = err.Error()
+	}
+	return self.result
+}
+
+// 检查超时或 panic 都视为失败
+func (self *healthCheck) safeCheck(ctx context.Context) (err error) {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("health check panic: %v", r)
+			}
+		}()
+		done <- self.check(ctx)
+	}()
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 处理健康检查请求，不是健康检查路径时返回 false
+func (self engineHealth) serve(response http.ResponseWriter, request *http.Request) bool {
+	if !self.enabled || request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return false
+	}
+	cfg := self.config
+	switch request.URL.Path {
+	case pathOrDefault(cfg.LivenessPath, "/healthz"):
+		for _, result := range self.report(request.Context(), true).Checks {
+			if result.Status != HealthStatusOK {
+				writeHealth(response, http.StatusServiceUnavailable, "fail")
+				return true
+			}
+		}
+		writeHealth(response, http.StatusOK, "ok")
+	case pathOrDefault(cfg.ReadinessPath, "/readyz"):
+		if !self.Ready() {
+			writeHealth(response, http.StatusServiceUnavailable, "not ready")
+			return true
+		}
+		if self.Report(request.Context()).Status == HealthStatusFail {
+			writeHealth(response, http.StatusServiceUnavailable, "fail")
+			return true
+		}
+		writeHealth(response, http.StatusOK, "ok")
+	case pathOrDefault(cfg.ReportPath, "/health"):
+		report := self.Report(request.Context())
+		status := http.StatusOK
+		if report.Status == HealthStatusFail || !report.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		response.Header().Set("Content-Type", MIMEJSON+"; charset=utf-8")
+		response.Header().Set("Cache-Control", "no-store")
+		response.WriteHeader(status)
+		json.NewEncoder(response).Encode(report)
+	default:
+		return false
+	}
+	return true
+}
+
+func writeHealth(response http.ResponseWriter, status int, text string) {
+	response.Header().Set("Content-Type", MIMEText+"; charset=utf-8")
+	response.Header().Set("Cache-Control", "no-store")
+	response.WriteHeader(status)
+	response.Write([]byte(text))
+}
+
+func pathOrDefault(path, def string) string {
+	if path == "" {
+		return def
+	}
+	return path
+}
@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"errors"
+	"math/big"
+)
+
+type algorithm struct {
+	hash  crypto.Hash
+	curve elliptic.Curve // 仅 ES*
+}
+
+// 支持的签名算法，不支持 none
+var algorithms = map[string]algorithm{
+	"HS256": {hash: crypto.SHA256},
+	"HS384": {hash: crypto.SHA384},
+	"HS512": {hash: crypto.SHA512},
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"ES256": {hash: crypto.SHA256, curve: elliptic.P256()},
+	"ES384": {hash: crypto.SHA384, curve: elliptic.P384()},
+	"ES512": {hash: crypto.SHA512, curve: elliptic.P521()},
+	"EdDSA": {},
+}
+
+var errKeyType = errors.New("jwt: key type does not match algorithm")
+
+// 私钥校验时使用其公钥
+func publicKey(key interface{}) interface{} {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	case ed25519.PrivateKey:
+		return k.Public()
+	}
+	return key
+}
+
+// 密钥类型与算法是否匹配，防止用公钥当作 HMAC 密钥等算法混淆攻击
+func keyMatches(alg string, key interface{}) bool {
+	key = publicKey(key)
+	switch alg[:2] {
+	case "HS":
+		_, ok := key.([]byte)
+		return ok
+	case "RS":
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		return ok && k.Curve == algorithms[alg].curve
+	case "Ed":
+		_, ok := key.(ed25519.PublicKey)
+		return ok
+	}
+	return false
+}
+
+func defaultAlgorithm(key interface{}) string {
+	switch k := publicKey(key).(type) {
+	case []byte:
+		return "HS256"
+	case *rsa.PublicKey:
+		return "RS256"
+	case *ecdsa.PublicKey:
+		for name, alg := range algorithms {
+			if alg.curve != nil && alg.curve == k.Curve {
+				return name
+			}
+		}
+	case ed25519.PublicKey:
+		return "EdDSA"
+	}
+	return ""
+}
+
+func digest(hash crypto.Hash, input []byte) []byte {
+	h := hash.New()
+	h.Write(input)
+	return h.Sum(nil)
+}
+
+func verify(alg string, key interface{}, input, signature []byte) error {
+	if !keyMatches(alg, key) {
+		return errKeyType
+	}
+	spec := algorithms[alg]
+	switch k := publicKey(key).(type) {
+	case []byte:
+		mac := hmac.New(spec.hash.New, k)
+		mac.Write(input)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, spec.hash, digest(spec.hash, input), signature)
+	case *ecdsa.PublicKey:
+		// 签名为定长的 r || s
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest(spec.hash, input), r, s) {
+			return ErrSignature
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, input, signature) {
+			return ErrSignature
+		}
+		return nil
+	}
+	return errKeyType
+}
+
+func sign(alg string, key interface{}, input []byte) ([]byte, error) {
+	if !keyMatches(alg, key) {
+		return nil, errKeyType
+	}
+	spec := algorithms[alg]
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(spec.hash.New, k)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, k, spec.hash, digest(spec.hash, input))
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest(spec.hash, input))
+		if err != nil {
+			return nil, err
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, input), nil
+	}
+	return nil, errors.New("jwt: signing requires a private key")
+}
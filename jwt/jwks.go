@@ -0,0 +1,267 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 从文件或 URL 加载的 JSON Web Key Set，定期刷新，遇到未知 kid 时立即刷新（有最小间隔）
+// 只有第一次加载需要等待，之后过期时在后台刷新，刷新完成前继续使用上一次加载的密钥。
+// 失败后同样要间隔 minRefresh 才会重试，身份提供方不可用时不会让每个请求都去等待超时
+type JWKS struct {
+	source     string
+	interval   time.Duration
+	minRefresh time.Duration
+	client     *http.Client
+
+	fetchMu     sync.Mutex
+	refreshing  atomic.Bool
+	mu          sync.RWMutex
+	keys        []Key
+	loadedAt    time.Time // 上一次成功加载的时间
+	lastAttempt time.Time // 上一次尝试加载的时间，无论成功与否
+	lastErr     error
+	modTime     time.Time
+}
+
+// source 为 http(s):// 开头的地址或本地文件路径（可带 file:// 前缀），interval 为定期刷新间隔，默认 1 小时
+func NewJWKS(source string, interval ...time.Duration) *JWKS {
+	jwks := &JWKS{
+		source:     strings.TrimPrefix(source, "file://"),
+		interval:   time.Hour,
+		minRefresh: 30 * time.Second,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	if len(interval) > 0 && interval[0] > 0 {
+		jwks.interval = interval[0]
+	}
+	return jwks
+}
+
+func (self *JWKS) remote() bool {
+	return strings.HasPrefix(self.source, "http://") || strings.HasPrefix(self.source, "https://")
+}
+
+func (self *JWKS) Keys(ctx context.Context) ([]Key, error) {
+	self.mu.RLock()
+	keys, loadedAt := self.keys, self.loadedAt
+	self.mu.RUnlock()
+	if loadedAt.IsZero() {
+		if err := self.load(ctx, self.minRefresh, true); err != nil {
+			return nil, err
+		}
+		self.mu.RLock()
+		defer self.mu.RUnlock()
+		return self.keys, nil
+	}
+	if time.Since(loadedAt) >= self.interval && self.refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer self.refreshing.Store(false)
+			self.load(context.Background(), self.minRefresh, false)
+		}()
+	}
+	return keys, nil
+}
+
+// 距上次加载超过最小间隔时重新加载，防止伪造 kid 的请求频繁触发；已经有请求在加载时不等待
+func (self *JWKS) RefreshKeys(ctx context.Context) error {
+	return self.load(ctx, self.minRefresh, false)
+}
+
+// 立即重新加载
+func (self *JWKS) Refresh(ctx context.Context) error {
+	return self.load(ctx, 0, true)
+}
+
+// wait 为 false 时，已经有其他请求在加载则直接返回
+func (self *JWKS) load(ctx context.Context, minInterval time.Duration, wait bool) error {
+	if wait {
+		self.fetchMu.Lock()
+	} else if !self.fetchMu.TryLock() {
+		return nil
+	}
+	defer self.fetchMu.Unlock()
+	self.mu.Lock()
+	// 等锁期间其他请求已经加载过，或者距上次失败还不到最小间隔
+	if !self.lastAttempt.IsZero() && time.Since(self.lastAttempt) < max(minInterval, time.Second) {
+		err := self.lastErr
+		if self.keys != nil {
+			err = nil
+		}
+		self.mu.Unlock()
+		return err
+	}
+	self.lastAttempt = time.Now()
+	self.mu.Unlock()
+
+	// 请求取消不应该中断加载，否则会进入失败后的重试间隔
+	data, modTime, err := self.read(context.WithoutCancel(ctx))
+	var keys []Key
+	if err == nil && data != nil {
+		keys, err = ParseJWKS(data)
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.lastErr = err
+	if err != nil {
+		return err
+	}
+	// 文件未修改时只更新加载时间
+	if data != nil {
+		self.keys, self.modTime = keys, modTime
+	}
+	self.loadedAt = time.Now()
+	return nil
+}
+
+func (self *JWKS) read(ctx context.Context) ([]byte, time.Time, error) {
+	if !self.remote() {
+		info, err := os.Stat(self.source)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		self.mu.RLock()
+		unchanged := !self.loadedAt.IsZero() && info.ModTime().Equal(self.modTime)
+		self.mu.RUnlock()
+		if unchanged {
+			return nil, info.ModTime(), nil
+		}
+		data, err := os.ReadFile(self.source)
+		return data, info.ModTime(), err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, self.source, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("jwt: fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("jwt: fetch jwks: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return data, time.Time{}, err
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// 解析 JWKS 文档，跳过用途不是签名或类型不支持的密钥
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwt: invalid jwks: %w", err)
+	}
+	keys := make([]Key, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwt: jwks key %q: %w", jwk.Kid, err)
+		}
+		if key == nil {
+			continue
+		}
+		keys = append(keys, Key{ID: jwk.Kid, Algorithm: jwk.Alg, Key: key})
+	}
+	return keys, nil
+}
+
+func (self jsonWebKey) publicKey() (interface{}, error) {
+	switch self.Kty {
+	case "RSA":
+		n, err := decodeBigInt(self.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(self.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch self.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeBigInt(self.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(self.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return key, nil
+	case "OKP":
+		if self.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(self.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		k, err := base64.RawURLEncoding.DecodeString(self.K)
+		if err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,165 @@
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 模拟身份提供方的 JWKS 接口，可以切换密钥和故障状态
+type jwksServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	body  string
+	fail  bool
+	delay time.Duration
+	hits  atomic.Int32
+}
+
+func newJWKSServer(secrets map[string]string) *jwksServer {
+	server := &jwksServer{}
+	server.setKeys(secrets)
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.hits.Add(1)
+		server.mu.Lock()
+		body, fail, delay := server.body, server.fail, server.delay
+		server.mu.Unlock()
+		time.Sleep(delay)
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	return server
+}
+
+func (self *jwksServer) setKeys(secrets map[string]string) {
+	body := `{"keys":[`
+	i := 0
+	for kid, secret := range secrets {
+		if i > 0 {
+			body += ","
+		}
+		body += fmt.Sprintf(`{"kty":"oct","kid":%q,"alg":"HS256","k":%q}`, kid, base64.RawURLEncoding.EncodeToString([]byte(secret)))
+		i++
+	}
+	self.mu.Lock()
+	self.body = body + "]}"
+	self.mu.Unlock()
+}
+
+func (self *jwksServer) setFail(fail bool, delay time.Duration) {
+	self.mu.Lock()
+	self.fail, self.delay = fail, delay
+	self.mu.Unlock()
+}
+
+// 让最小刷新间隔立即到期
+func (self *JWKS) expireAttempt() {
+	self.mu.Lock()
+	self.lastAttempt = time.Now().Add(-time.Hour)
+	self.mu.Unlock()
+}
+
+func TestJWKSKeyRotation(t *testing.T) {
+	server := newJWKSServer(map[string]string{"a": "secret-a"})
+	defer server.Close()
+	jwks := NewJWKS(server.URL)
+	verifier := &Verifier{Keys: jwks}
+	ctx := context.Background()
+
+	if _, err := verifier.Verify(ctx, mustSign(t, Key{ID: "a", Key: []byte("secret-a")}, Claims{})); err != nil {
+		t.Fatal(err)
+	}
+	server.setKeys(map[string]string{"a": "secret-a", "b": "secret-b"})
+	tokenB := mustSign(t, Key{ID: "b", Key: []byte("secret-b")}, Claims{})
+	// 刚加载过，未知 kid 不会触发刷新
+	if _, err := verifier.Verify(ctx, tokenB); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("got %v, want ErrKeyNotFound within minRefresh", err)
+	}
+	jwks.expireAttempt()
+	if _, err := verifier.Verify(ctx, tokenB); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if hits := server.hits.Load(); hits != 2 {
+		t.Fatalf("jwks fetched %d times, want 2", hits)
+	}
+}
+
+func TestJWKSFailureBackoff(t *testing.T) {
+	server := newJWKSServer(map[string]string{"a": "secret-a"})
+	defer server.Close()
+	server.setFail(true, 0)
+	jwks := NewJWKS(server.URL)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if _, err := jwks.Keys(ctx); err == nil {
+			t.Fatal("Keys succeeded while the server is down")
+		}
+	}
+	if hits := server.hits.Load(); hits != 1 {
+		t.Fatalf("first load retried %d times during backoff, want 1", hits)
+	}
+
+	server.setFail(false, 0)
+	jwks.expireAttempt()
+	if _, err := jwks.Keys(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 已有密钥时，伪造 kid 在身份提供方故障期间只触发一次请求，且不返回服务端错误
+	server.setFail(true, 0)
+	jwks.expireAttempt()
+	verifier := &Verifier{Keys: jwks}
+	forged := mustSign(t, Key{ID: "forged", Key: []byte("x")}, Claims{})
+	before := server.hits.Load()
+	for i := 0; i < 5; i++ {
+		verifier.Verify(ctx, forged)
+	}
+	if hits := server.hits.Load() - before; hits != 1 {
+		t.Fatalf("forged kid fetched %d times, want 1", hits)
+	}
+	if _, err := verifier.Verify(ctx, forged); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("got %v, want ErrKeyNotFound during backoff", err)
+	}
+}
+
+func TestJWKSBackgroundRefresh(t *testing.T) {
+	server := newJWKSServer(map[string]string{"a": "secret-a"})
+	defer server.Close()
+	jwks := NewJWKS(server.URL, time.Millisecond)
+	ctx := context.Background()
+	if _, err := jwks.Keys(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server.setKeys(map[string]string{"b": "secret-b"})
+	server.setFail(false, 200*time.Millisecond)
+	jwks.expireAttempt()
+	time.Sleep(5 * time.Millisecond)
+
+	// 过期后不等待刷新，先返回旧的密钥
+	start := time.Now()
+	keys, err := jwks.Keys(ctx)
+	if err != nil || len(keys) != 1 || keys[0].ID != "a" {
+		t.Fatalf("stale keys = %v, %v", keys, err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("Keys blocked for %s while refreshing", elapsed)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if keys, _ = jwks.Keys(ctx); len(keys) == 1 && keys[0].ID == "b" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("background refresh did not load the new keys")
+}
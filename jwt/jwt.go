@@ -0,0 +1,288 @@
+// JSON Web Token 的解析、签名校验与签发，支持 HS256/RS256/ES256/EdDSA 及其 384、512 变体
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenMalformed   = errors.New("jwt: token is malformed")
+	ErrAlgorithm        = errors.New("jwt: signing algorithm is not allowed")
+	ErrKeyNotFound      = errors.New("jwt: no key found for token")
+	ErrSignature        = errors.New("jwt: signature is invalid")
+	ErrTokenExpired     = errors.New("jwt: token is expired")
+	ErrTokenNotValidYet = errors.New("jwt: token is not valid yet")
+	ErrIssuer           = errors.New("jwt: token has invalid issuer")
+	ErrAudience         = errors.New("jwt: token has invalid audience")
+)
+
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// 注册声明，自定义声明的结构体内嵌 Claims 后通过 Token.Decode 解析
+//
+//	type UserClaims struct {
+//		jwt.Claims
+//		Role string `json:"role"`
+//	}
+type Claims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+}
+
+// 秒级时间戳，解析时兼容小数
+type NumericDate struct {
+	time.Time
+}
+
+func NewNumericDate(t time.Time) *NumericDate {
+	return &NumericDate{t.Truncate(time.Second)}
+}
+
+func (self NumericDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(self.Unix(), 10)), nil
+}
+
+func (self *NumericDate) UnmarshalJSON(data []byte) error {
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	sec, frac := math.Modf(value)
+	self.Time = time.Unix(int64(sec), int64(frac*1e9))
+	return nil
+}
+
+// aud 可以是字符串或字符串数组
+type Audience []string
+
+func (self Audience) MarshalJSON() ([]byte, error) {
+	if len(self) == 1 {
+		return json.Marshal(self[0])
+	}
+	return json.Marshal([]string(self))
+}
+
+func (self *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*self = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*self = list
+	return nil
+}
+
+func (self Audience) Contains(aud string) bool {
+	for _, item := range self {
+		if item == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// 校验通过的 token
+type Token struct {
+	Raw     string
+	Header  Header
+	Claims  Claims
+	payload []byte
+}
+
+// 把载荷解析到自定义的声明结构体
+func (self *Token) Decode(v interface{}) error {
+	return json.Unmarshal(self.payload, v)
+}
+
+// 校验 token 的签名和注册声明
+type Verifier struct {
+	// 校验签名的密钥，见 StaticKeys、NewJWKS
+	Keys KeyProvider
+	// 不为空时 iss 必须相等
+	Issuer string
+	// 不为空时 aud 必须包含
+	Audience string
+	// 校验 exp、nbf 时允许的时钟偏差
+	Leeway time.Duration
+	// 允许的签名算法，为空时允许所有支持的算法
+	Algorithms []string
+}
+
+// 解析并校验 token
+func (self *Verifier) Verify(ctx context.Context, raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	token := &Token{Raw: raw}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(headerJSON, &token.Header) != nil {
+		return nil, ErrTokenMalformed
+	}
+	if token.payload, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err = json.Unmarshal(token.payload, &token.Claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if !self.allowed(token.Header.Algorithm) {
+		return nil, ErrAlgorithm
+	}
+	if err = self.verifySignature(ctx, token.Header, []byte(raw[:len(parts[0])+1+len(parts[1])]), signature); err != nil {
+		return nil, err
+	}
+	if err = self.validate(token.Claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (self *Verifier) allowed(alg string) bool {
+	if _, ok := algorithms[alg]; !ok {
+		return false
+	}
+	if len(self.Algorithms) == 0 {
+		return true
+	}
+	for _, item := range self.Algorithms {
+		if item == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// 有 kid 时只使用对应的密钥，找不到时刷新一次密钥（如 JWKS 轮换）；没有 kid 时逐个尝试算法匹配的密钥
+func (self *Verifier) verifySignature(ctx context.Context, header Header, input, signature []byte) error {
+	if self.Keys == nil {
+		return ErrKeyNotFound
+	}
+	keys, err := self.candidates(ctx, header)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 && header.KeyID != "" {
+		if refresher, ok := self.Keys.(KeyRefresher); ok {
+			if err = refresher.RefreshKeys(ctx); err != nil {
+				return err
+			}
+			if keys, err = self.candidates(ctx, header); err != nil {
+				return err
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return ErrKeyNotFound
+	}
+	for _, key := range keys {
+		if verify(header.Algorithm, key.Key, input, signature) == nil {
+			return nil
+		}
+	}
+	return ErrSignature
+}
+
+func (self *Verifier) candidates(ctx context.Context, header Header) ([]Key, error) {
+	keys, err := self.Keys.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list []Key
+	for _, key := range keys {
+		if header.KeyID != "" && key.ID != header.KeyID {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+			continue
+		}
+		if !keyMatches(header.Algorithm, key.Key) {
+			continue
+		}
+		list = append(list, key)
+	}
+	return list, nil
+}
+
+func (self *Verifier) validate(claims Claims, now time.Time) error {
+	if claims.ExpiresAt != nil && !now.Before(claims.ExpiresAt.Add(self.Leeway)) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != nil && now.Add(self.Leeway).Before(claims.NotBefore.Time) {
+		return ErrTokenNotValidYet
+	}
+	if self.Issuer != "" && claims.Issuer != self.Issuer {
+		return ErrIssuer
+	}
+	if self.Audience != "" && !claims.Audience.Contains(self.Audience) {
+		return ErrAudience
+	}
+	return nil
+}
+
+// 签发 token，claims 通常是内嵌了 Claims 的结构体，key.Algorithm 为空时按密钥类型选择默认算法
+func Sign(key Key, claims interface{}) (string, error) {
+	alg := key.Algorithm
+	if alg == "" {
+		alg = defaultAlgorithm(key.Key)
+	}
+	if _, ok := algorithms[alg]; !ok {
+		return "", ErrAlgorithm
+	}
+	headerJSON, err := json.Marshal(Header{Algorithm: alg, KeyID: key.ID, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(headerJSON))
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(payload))
+	signature, err := sign(alg, key.Key, buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(signature))
+	return buf.String(), nil
+}
+
+type tokenKey struct{}
+
+// 把 token 放入 context，下游通过 TokenFromContext 取出
+func ContextWithToken(ctx context.Context, token *Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// 取出 ctx 中的 token，没有时返回 nil
+func TokenFromContext(ctx context.Context) *Token {
+	token, _ := ctx.Value(tokenKey{}).(*Token)
+	return token
+}
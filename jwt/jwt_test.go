@@ -0,0 +1,126 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustSign(t *testing.T, key Key, claims interface{}) string {
+	t.Helper()
+	token, err := Sign(key, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// 用任意 header 和 HMAC 密钥手工构造 token
+func forgeHMAC(t *testing.T, header Header, claims Claims, secret []byte) string {
+	t.Helper()
+	headerJSON, _ := json.Marshal(header)
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := sign("HS256", secret, []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyAlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := &Verifier{Keys: StaticKeys{{ID: "rsa", Key: &rsaKey.PublicKey}}}
+	claims := Claims{Subject: "alice"}
+
+	if _, err = verifier.Verify(context.Background(), mustSign(t, Key{ID: "rsa", Key: rsaKey}, claims)); err != nil {
+		t.Fatalf("RS256 token: %v", err)
+	}
+	// 用公钥当作 HMAC 密钥签名
+	forged := forgeHMAC(t, Header{Algorithm: "HS256", KeyID: "rsa"}, claims, der)
+	if _, err = verifier.Verify(context.Background(), forged); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("HS256 signed with public key: got %v, want ErrKeyNotFound", err)
+	}
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice"}`)) + "."
+	if _, err = verifier.Verify(context.Background(), none); !errors.Is(err, ErrAlgorithm) {
+		t.Fatalf("alg none: got %v, want ErrAlgorithm", err)
+	}
+	// 曲线与算法不一致：ES256 的 token 不能用 P-384 的密钥校验
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecVerifier := &Verifier{Keys: StaticKeys{{Key: &p384.PublicKey}}}
+	if _, err = ecVerifier.Verify(context.Background(), mustSign(t, Key{Key: p256}, claims)); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("ES256 token with a P-384 key: got %v, want ErrKeyNotFound", err)
+	}
+	restricted := &Verifier{Keys: HMACKey([]byte("secret")), Algorithms: []string{"HS512"}}
+	if _, err = restricted.Verify(context.Background(), mustSign(t, Key{Key: []byte("secret")}, claims)); !errors.Is(err, ErrAlgorithm) {
+		t.Fatalf("algorithm not in Algorithms: got %v, want ErrAlgorithm", err)
+	}
+}
+
+func TestVerifyLeeway(t *testing.T) {
+	key := Key{Key: []byte("secret")}
+	now := time.Now()
+	tests := []struct {
+		name   string
+		claims Claims
+		leeway time.Duration
+		want   error
+	}{
+		{"valid", Claims{ExpiresAt: NewNumericDate(now.Add(time.Minute))}, 0, nil},
+		{"expired", Claims{ExpiresAt: NewNumericDate(now.Add(-10 * time.Second))}, 0, ErrTokenExpired},
+		{"expired within leeway", Claims{ExpiresAt: NewNumericDate(now.Add(-10 * time.Second))}, 30 * time.Second, nil},
+		{"expired beyond leeway", Claims{ExpiresAt: NewNumericDate(now.Add(-time.Minute))}, 30 * time.Second, ErrTokenExpired},
+		{"not valid yet", Claims{NotBefore: NewNumericDate(now.Add(10 * time.Second))}, 0, ErrTokenNotValidYet},
+		{"not valid yet within leeway", Claims{NotBefore: NewNumericDate(now.Add(10 * time.Second))}, 30 * time.Second, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := &Verifier{Keys: StaticKeys{key}, Leeway: tt.leeway}
+			_, err := verifier.Verify(context.Background(), mustSign(t, key, tt.claims))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyIssuerAudience(t *testing.T) {
+	key := Key{Key: []byte("secret")}
+	verifier := &Verifier{Keys: StaticKeys{key}, Issuer: "https://auth.example.com", Audience: "api"}
+	token := mustSign(t, key, Claims{Issuer: "https://auth.example.com", Audience: Audience{"web", "api"}, Subject: "alice"})
+	parsed, err := verifier.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Claims.Subject != "alice" {
+		t.Fatalf("subject = %q", parsed.Claims.Subject)
+	}
+	if _, err = verifier.Verify(context.Background(), mustSign(t, key, Claims{Issuer: "evil", Audience: Audience{"api"}})); !errors.Is(err, ErrIssuer) {
+		t.Fatalf("got %v, want ErrIssuer", err)
+	}
+	if _, err = verifier.Verify(context.Background(), mustSign(t, key, Claims{Issuer: "https://auth.example.com", Audience: Audience{"web"}})); !errors.Is(err, ErrAudience) {
+		t.Fatalf("got %v, want ErrAudience", err)
+	}
+}
@@ -0,0 +1,74 @@
+package jwt
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// 签名或校验用的密钥
+type Key struct {
+	// 对应 token 头中的 kid，为空时匹配所有 token
+	ID string
+	// 限定算法，为空时按密钥类型匹配
+	Algorithm string
+	// HMAC 为 []byte；RSA、ECDSA、Ed25519 校验时为公钥（也接受私钥），签发时为私钥
+	Key interface{}
+}
+
+// 提供校验用的密钥
+type KeyProvider interface {
+	Keys(ctx context.Context) ([]Key, error)
+}
+
+// token 的 kid 找不到对应密钥时，Verifier 调用 RefreshKeys 重新加载一次，用于密钥轮换
+type KeyRefresher interface {
+	RefreshKeys(ctx context.Context) error
+}
+
+// 固定的密钥列表
+type StaticKeys []Key
+
+func (self StaticKeys) Keys(ctx context.Context) ([]Key, error) {
+	return self, nil
+}
+
+// HMAC 密钥
+func HMACKey(secret []byte) StaticKeys {
+	return StaticKeys{{Key: secret}}
+}
+
+// 解析 PEM 格式的公钥或证书
+func ParsePublicKeyPEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: invalid PEM data")
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// 解析 PEM 格式的私钥，支持 PKCS#1、PKCS#8 和 SEC 1
+func ParsePrivateKeyPEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: invalid PEM data")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
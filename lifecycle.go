@@ -0,0 +1,141 @@
+// 生命周期钩子：启动前、就绪后、关闭时
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultStartTimeout        = 30 * time.Second
+	defaultShutdownTimeout     = 30 * time.Second
+	defaultShutdownHookTimeout = 30 * time.Second
+)
+
+// 生命周期钩子，ctx 带有超时，钩子应在 ctx 结束前返回
+type Hook func(ctx context.Context) error
+
+// 关闭时执行的钩子，如关闭数据库连接池、刷新日志
+type ShutdownHook = Hook
+
+// 服务容器中的服务实现以下接口即可参与生命周期，见 RegisterLifecycle、UseServices
+type StartAware interface {
+	OnStart(ctx context.Context) error
+}
+
+type ReadyAware interface {
+	OnReady(ctx context.Context) error
+}
+
+type ShutdownAware interface {
+	OnShutdown(ctx context.Context) error
+}
+
+// 注册启动钩子，路由注册完成后、开始监听前按注册顺序执行，如预热缓存、检查依赖
+// 任一钩子出错则启动失败，后续钩子不再执行
+func (self *Engine) OnStart(hooks ...Hook) {
+	self.startHooks = append(self.startHooks, hooks...)
+}
+
+// 注册就绪钩子，开始监听后按注册顺序执行，如注册到服务发现。出错同样视为启动失败
+func (self *Engine) OnReady(hooks ...Hook) {
+	self.readyHooks = append(self.readyHooks, hooks...)
+}
+
+// 注册关闭钩子，按注册的相反顺序执行（后注册的先执行，与 defer 一致）
+func (self *Engine) OnShutdown(hooks ...Hook) {
+	self.shutdownHooks = append(self.shutdownHooks, hooks...)
+}
+
+// 按实现的接口注册 target 的生命周期钩子
+func (self *Engine) RegisterLifecycle(targets ...interface{}) {
+	for _, target := range targets {
+		if t, ok := target.(StartAware); ok {
+			self.OnStart(t.OnStart)
+		}
+		if t, ok := target.(ReadyAware); ok {
+			self.OnReady(t.OnReady)
+		}
+		if t, ok := target.(ShutdownAware); ok {
+			self.OnShutdown(t.OnShutdown)
+		}
+	}
+}
+
+// 从服务容器取出服务并注册其生命周期钩子
+func (self *Engine) UseServices(names ...string) {
+	for _, name := range names {
+		self.RegisterLifecycle(self.container.NewSingle(name))
+	}
+}
+
+// 执行启动钩子，遇到错误立即返回
+func (self *Engine) RunStartHooks(ctx context.Context) error {
+	return runHooks(ctx, "start", self.startHooks)
+}
+
+// 执行就绪钩子，遇到错误立即返回
+func (self *Engine) RunReadyHooks(ctx context.Context) error {
+	return runHooks(ctx, "ready", self.readyHooks)
+}
+
+func runHooks(ctx context.Context, stage string, hooks []Hook) error {
+	for i, hook := range hooks {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%s hook %d: %w", stage, i, err)
+		}
+		if err := hook(ctx); err != nil {
+			return fmt.Errorf("%s hook %d: %w", stage, i, err)
+		}
+	}
+	return nil
+}
+
+// 执行关闭钩子，某个钩子出错不影响后续钩子执行，返回所有错误
+func (self *Engine) RunShutdownHooks(ctx context.Context) error {
+	var errs []error
+	for i := len(self.shutdownHooks) - 1; i >= 0; i-- {
+		if err := self.shutdownHooks[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 启动钩子、就绪钩子各自的最长执行时间，可通过 http.startTimeout 配置，默认 30s
+func (self *Engine) SetStartTimeout(timeout time.Duration) {
+	self.startTimeout = timeout
+}
+
+func (self *Engine) StartTimeout() time.Duration {
+	if self.startTimeout <= 0 {
+		return defaultStartTimeout
+	}
+	return self.startTimeout
+}
+
+// 等待处理中的请求完成的最长时间，可通过 http.shutdownTimeout 配置，默认 30s
+func (self *Engine) SetShutdownTimeout(timeout time.Duration) {
+	self.shutdownTimeout = timeout
+}
+
+func (self *Engine) ShutdownTimeout() time.Duration {
+	if self.shutdownTimeout <= 0 {
+		return defaultShutdownTimeout
+	}
+	return self.shutdownTimeout
+}
+
+// 关闭钩子的最长执行时间，在请求处理完成之后单独计时，可通过 http.shutdownHookTimeout 配置，默认 30s
+func (self *Engine) SetShutdownHookTimeout(timeout time.Duration) {
+	self.shutdownHookTimeout = timeout
+}
+
+func (self *Engine) ShutdownHookTimeout() time.Duration {
+	if self.shutdownHookTimeout <= 0 {
+		return defaultShutdownHookTimeout
+	}
+	return self.shutdownHookTimeout
+}
@@ -0,0 +1,186 @@
+// Prometheus 文本格式的指标，不依赖外部库
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 默认的延迟分桶，单位秒
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// 可以并发修改的 float64
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (self *atomicFloat) Add(delta float64) {
+	for {
+		old := self.bits.Load()
+		if self.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (self *atomicFloat) Set(val float64) {
+	self.bits.Store(math.Float64bits(val))
+}
+
+func (self *atomicFloat) Load() float64 {
+	return math.Float64frombits(self.bits.Load())
+}
+
+// 计数器，只增不减
+type Counter struct {
+	val atomicFloat
+}
+
+func (self *Counter) Inc() {
+	self.val.Add(1)
+}
+
+// delta 小于 0 时 panic
+func (self *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	self.val.Add(delta)
+}
+
+func (self *Counter) Value() float64 {
+	return self.val.Load()
+}
+
+// 仪表盘，可增可减
+type Gauge struct {
+	val atomicFloat
+}
+
+func (self *Gauge) Set(val float64) {
+	self.val.Set(val)
+}
+
+func (self *Gauge) Inc() {
+	self.val.Add(1)
+}
+
+func (self *Gauge) Dec() {
+	self.val.Add(-1)
+}
+
+func (self *Gauge) Add(delta float64) {
+	self.val.Add(delta)
+}
+
+func (self *Gauge) Value() float64 {
+	return self.val.Load()
+}
+
+// 直方图
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64 // 每个分桶的计数（不累加），最后一个为 +Inf
+	sum     atomicFloat
+	count   atomic.Uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets)+1)}
+}
+
+func (self *Histogram) Observe(val float64) {
+	i := sort.SearchFloat64s(self.buckets, val)
+	self.counts[i].Add(1)
+	self.sum.Add(val)
+	self.count.Add(1)
+}
+
+// 一组名称相同、标签值不同的时间序列
+type vec[T any] struct {
+	labels []string
+	newFn  func() T
+	mu     sync.RWMutex
+	series map[string]*series[T]
+}
+
+type series[T any] struct {
+	values []string
+	metric T
+}
+
+func newVec[T any](labels []string, newFn func() T) *vec[T] {
+	return &vec[T]{labels: labels, newFn: newFn, series: map[string]*series[T]{}}
+}
+
+// 按标签值取时间序列，不存在时创建。标签值数量与注册时的标签数量不一致时 panic
+func (self *vec[T]) with(values ...string) T {
+	if len(values) != len(self.labels) {
+		panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(self.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	self.mu.RLock()
+	s, ok := self.series[key]
+	self.mu.RUnlock()
+	if ok {
+		return s.metric
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if s, ok := self.series[key]; ok {
+		return s.metric
+	}
+	s = &series[T]{values: append([]string{}, values...), metric: self.newFn()}
+	self.series[key] = s
+	return s.metric
+}
+
+// 按标签值排序的时间序列，输出结果稳定
+func (self *vec[T]) sorted() []*series[T] {
+	self.mu.RLock()
+	list := make([]*series[T], 0, len(self.series))
+	for _, s := range self.series {
+		list = append(list, s)
+	}
+	self.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return strings.Join(list[i].values, "\xff") < strings.Join(list[j].values, "\xff")
+	})
+	return list
+}
+
+type CounterVec struct {
+	*vec[*Counter]
+}
+
+// 按标签值取计数器，顺序与注册时的标签一致
+func (self *CounterVec) With(values ...string) *Counter {
+	return self.with(values...)
+}
+
+type GaugeVec struct {
+	*vec[*Gauge]
+}
+
+func (self *GaugeVec) With(values ...string) *Gauge {
+	return self.with(values...)
+}
+
+type HistogramVec struct {
+	*vec[*Histogram]
+	buckets []float64
+}
+
+func (self *HistogramVec) With(values ...string) *Histogram {
+	return self.with(values...)
+}
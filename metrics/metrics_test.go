@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	counter := &Counter{}
+	counter.Inc()
+	counter.Add(2.5)
+	if got := counter.Value(); got != 3.5 {
+		t.Fatalf("value = %v, want 3.5", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Add(-1) did not panic")
+		}
+	}()
+	counter.Add(-1)
+}
+
+func TestGauge(t *testing.T) {
+	gauge := &Gauge{}
+	gauge.Set(10)
+	gauge.Inc()
+	gauge.Dec()
+	gauge.Dec()
+	gauge.Add(-0.5)
+	if got := gauge.Value(); got != 8.5 {
+		t.Fatalf("value = %v, want 8.5", got)
+	}
+}
+
+func TestHistogramObserve(t *testing.T) {
+	h := newHistogram([]float64{0.1, 0.5, 1})
+	// 等于上界的值落在该分桶
+	for _, val := range []float64{0.05, 0.1, 0.3, 1, 2, 7} {
+		h.Observe(val)
+	}
+	want := []uint64{2, 1, 1, 2}
+	for i := range want {
+		if got := h.counts[i].Load(); got != want[i] {
+			t.Fatalf("bucket %d = %d, want %d", i, got, want[i])
+		}
+	}
+	if h.count.Load() != 6 || h.sum.Load() != 10.45 {
+		t.Fatalf("count = %d, sum = %v", h.count.Load(), h.sum.Load())
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	counters := NewRegistry().Counter("requests_total", "", "route")
+	gauge := &Gauge{}
+	h := newHistogram(DefBuckets)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counters.With("/orders").Inc()
+				gauge.Inc()
+				gauge.Dec()
+				h.Observe(0.01)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := counters.With("/orders").Value(); got != 8000 {
+		t.Fatalf("counter = %v, want 8000", got)
+	}
+	if got := gauge.Value(); got != 0 {
+		t.Fatalf("gauge = %v, want 0", got)
+	}
+	if got := h.count.Load(); got != 8000 {
+		t.Fatalf("histogram count = %d, want 8000", got)
+	}
+}
+
+func TestVecLabelCount(t *testing.T) {
+	counters := NewRegistry().Counter("requests_total", "", "method", "route")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("With with a missing label value did not panic")
+		}
+	}()
+	counters.With("GET")
+}
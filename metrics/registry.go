@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 文本格式的 Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRe  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// 指标注册中心
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]*metric
+}
+
+type metric struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	write  func(w *bufio.Writer, m *metric)
+	value  interface{} // *CounterVec、*GaugeVec、*HistogramVec 或 func() float64
+}
+
+// 默认注册中心，中间件和 Handler 不传注册中心时使用
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]*metric{}}
+}
+
+// 注册计数器，同名同类型同标签重复注册时返回已有的，否则 panic
+func (self *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	m := self.register(name, help, typeCounter, labels, func() interface{} {
+		return &CounterVec{newVec(labels, func() *Counter { return &Counter{} })}
+	}, writeCounters)
+	return m.value.(*CounterVec)
+}
+
+// 注册仪表盘
+func (self *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	m := self.register(name, help, typeGauge, labels, func() interface{} {
+		return &GaugeVec{newVec(labels, func() *Gauge { return &Gauge{} })}
+	}, writeGauges)
+	return m.value.(*GaugeVec)
+}
+
+// 注册直方图，buckets 为空时使用 DefBuckets
+func (self *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	m := self.register(name, help, typeHistogram, labels, func() interface{} {
+		return &HistogramVec{vec: newVec(labels, func() *Histogram { return newHistogram(buckets) }), buckets: buckets}
+	}, writeHistograms)
+	return m.value.(*HistogramVec)
+}
+
+// 注册输出时才取值的仪表盘，如连接数、队列长度
+func (self *Registry) GaugeFunc(name, help string, fn func() float64) {
+	self.register(name, help, typeGauge, nil, func() interface{} { return fn }, writeFunc)
+}
+
+// 注册输出时才取值的计数器
+func (self *Registry) CounterFunc(name, help string, fn func() float64) {
+	self.register(name, help, typeCounter, nil, func() interface{} { return fn }, writeFunc)
+}
+
+// 移除指标
+func (self *Registry) Unregister(name string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.metrics, name)
+}
+
+func (self *Registry) register(name, help, typ string, labels []string, newValue func() interface{}, write func(w *bufio.Writer, m *metric)) *metric {
+	if !metricNameRe.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	for _, label := range labels {
+		if !labelNameRe.MatchString(label) || strings.HasPrefix(label, "__") || label == "le" {
+			panic(fmt.Sprintf("metrics: invalid label name %q", label))
+		}
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if m, ok := self.metrics[name]; ok {
+		_, isFunc := m.value.(func() float64)
+		if m.typ != typ || isFunc || strings.Join(m.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("metrics: %s already registered with different type or labels", name))
+		}
+		return m
+	}
+	m := &metric{name: name, help: help, typ: typ, labels: labels, write: write, value: newValue()}
+	self.metrics[name] = m
+	return m
+}
+
+// 以 Prometheus 文本格式输出所有指标，按名称排序
+func (self *Registry) WriteTo(w io.Writer) (int64, error) {
+	self.mu.RLock()
+	list := make([]*metric, 0, len(self.metrics))
+	for _, m := range self.metrics {
+		list = append(list, m)
+	}
+	self.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range list {
+		if m.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.typ)
+		m.write(bw, m)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// 实现 http.Handler，输出 /metrics
+func (self *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	self.WriteTo(w)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (self *countWriter) Write(p []byte) (int, error) {
+	n, err := self.w.Write(p)
+	self.n += int64(n)
+	return n, err
+}
+
+func writeCounters(w *bufio.Writer, m *metric) {
+	v := m.value.(*CounterVec)
+	for _, s := range v.sorted() {
+		writeSample(w, m.name, m.labels, s.values, "", "", s.metric.Value())
+	}
+}
+
+func writeGauges(w *bufio.Writer, m *metric) {
+	v := m.value.(*GaugeVec)
+	for _, s := range v.sorted() {
+		writeSample(w, m.name, m.labels, s.values, "", "", s.metric.Value())
+	}
+}
+
+func writeHistograms(w *bufio.Writer, m *metric) {
+	v := m.value.(*HistogramVec)
+	for _, s := range v.sorted() {
+		h := s.metric
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += h.counts[i].Load()
+			writeSample(w, m.name+"_bucket", m.labels, s.values, "le", formatFloat(bound), float64(cumulative))
+		}
+		cumulative += h.counts[len(h.buckets)].Load()
+		writeSample(w, m.name+"_bucket", m.labels, s.values, "le", "+Inf", float64(cumulative))
+		writeSample(w, m.name+"_sum", m.labels, s.values, "", "", h.sum.Load())
+		writeSample(w, m.name+"_count", m.labels, s.values, "", "", float64(h.count.Load()))
+	}
+}
+
+func writeFunc(w *bufio.Writer, m *metric) {
+	writeSample(w, m.name, nil, nil, "", "", m.value.(func() float64)())
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, val float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label)
+			w.WriteString(`="`)
+			w.WriteString(escapeLabelValue(values[i]))
+			w.WriteByte('"')
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraLabel)
+			w.WriteString(`="`)
+			w.WriteString(extraValue)
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(val))
+	w.WriteByte('\n')
+}
+
+func formatFloat(val float64) string {
+	switch {
+	case math.IsInf(val, 1):
+		return "+Inf"
+	case math.IsInf(val, -1):
+		return "-Inf"
+	case math.IsNaN(val):
+		return "NaN"
+	}
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func exposition(t *testing.T, registry *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := registry.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+	return buf.String()
+}
+
+func TestWriteCountersAndGauges(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.Counter("http_requests_total", "Total requests.\nBy route \\ method.", "method", "route")
+	requests.With("POST", "/orders").Add(2)
+	requests.With("GET", `/search?q="a\b"`+"\n").Inc()
+	registry.Gauge("queue_length", "").With().Set(-3)
+	registry.GaugeFunc("connections", "Open connections.", func() float64 { return 5 })
+
+	want := `# HELP connections Open connections.
+# TYPE connections gauge
+connections 5
+# HELP http_requests_total Total requests.\nBy route \\ method.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/search?q=\"a\\b\"\n"} 1
+http_requests_total{method="POST",route="/orders"} 2
+# TYPE queue_length gauge
+queue_length -3
+`
+	if got := exposition(t, registry); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteHistogram(t *testing.T) {
+	registry := NewRegistry()
+	latency := registry.Histogram("request_seconds", "Request latency.", []float64{1, 0.1, 0.5}, "route")
+	for _, val := range []float64{0.05, 0.1, 0.3, 1, 2} {
+		latency.With("/orders").Observe(val)
+	}
+
+	want := `# HELP request_seconds Request latency.
+# TYPE request_seconds histogram
+request_seconds_bucket{route="/orders",le="0.1"} 2
+request_seconds_bucket{route="/orders",le="0.5"} 3
+request_seconds_bucket{route="/orders",le="1"} 4
+request_seconds_bucket{route="/orders",le="+Inf"} 5
+request_seconds_sum{route="/orders"} 3.45
+request_seconds_count{route="/orders"} 5
+`
+	if got := exposition(t, registry); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegisterConflicts(t *testing.T) {
+	registry := NewRegistry()
+	first := registry.Counter("jobs_total", "", "queue")
+	if registry.Counter("jobs_total", "", "queue") != first {
+		t.Fatal("registering the same counter twice returned a new one")
+	}
+	tests := []struct {
+		name     string
+		register func()
+	}{
+		{"different type", func() { registry.Gauge("jobs_total", "", "queue") }},
+		{"different labels", func() { registry.Counter("jobs_total", "", "status") }},
+		{"invalid metric name", func() { registry.Counter("jobs-total", "") }},
+		{"reserved label", func() { registry.Histogram("job_seconds", "", nil, "le") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("register did not panic")
+				}
+			}()
+			tt.register()
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.Counter("up", "").With().Inc()
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Header().Get("Content-Type") != ContentType || !strings.Contains(w.Body.String(), "up 1\n") {
+		t.Fatalf("response = %q %q", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
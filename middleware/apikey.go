@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/textthree/cvgoweb"
+	"net/http"
+)
+
+// API Key 对应的调用方
+type APIKey struct {
+	// 用于日志、限流等的标识，不是密钥本身
+	ID     string
+	Scopes []string
+	// 其他附加信息，写入 Principal.Attributes
+	Attributes map[string]interface{}
+}
+
+// API Key 存储，密钥不存在时返回 nil, nil
+type APIKeyStore interface {
+	Lookup(ctx context.Context, key string) (*APIKey, error)
+}
+
+// 密钥的 SHA-256 摘要（十六进制），存储中保存摘要而不是明文
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// 进程内的 API Key 存储
+type MemoryAPIKeyStore struct {
+	keys map[string]*APIKey
+}
+
+// keys 的 key 为 HashAPIKey 计算的摘要
+func NewMemoryAPIKeyStore(keys map[string]APIKey) *MemoryAPIKeyStore {
+	store := &MemoryAPIKeyStore{keys: make(map[string]*APIKey, len(keys))}
+	for hash, key := range keys {
+		key := key
+		store.keys[hash] = &key
+	}
+	return store
+}
+
+// 按摘要查找，查找耗时与密钥内容无关
+func (self *MemoryAPIKeyStore) Lookup(ctx context.Context, key string) (*APIKey, error) {
+	return self.keys[HashAPIKey(key)], nil
+}
+
+type APIKeyConfig struct {
+	Store APIKeyStore
+	// 密钥的来源，格式同 JWTConfig.TokenLookup，默认 ["header:X-API-Key"]
+	KeyLookup []string
+	// 要求密钥拥有的授权范围，缺少时返回 403
+	Scopes []string
+}
+
+// API Key 鉴权，通过后 ctx.Principal() 的 ID 为 APIKey.ID，Method 为 apikey
+func APIKeyAuth(cfg APIKeyConfig) httpserver.MiddlewareHandler {
+	if cfg.Store == nil {
+		panic("api key middleware: Store is required")
+	}
+	if len(cfg.KeyLookup) == 0 {
+		cfg.KeyLookup = []string{"header:X-API-Key"}
+	}
+	extractors := make([]tokenExtractor, 0, len(cfg.KeyLookup))
+	for _, lookup := range cfg.KeyLookup {
+		extractors = append(extractors, newTokenExtractor(lookup))
+	}
+
+	return func(c *httpserver.Context) error {
+		var key string
+		for _, extract := range extractors {
+			if key = extract(c); key != "" {
+				break
+			}
+		}
+		if key == "" {
+			return httpserver.NewHTTPError(http.StatusUnauthorized, "missing api key")
+		}
+		apiKey, err := cfg.Store.Lookup(c, key)
+		if err != nil {
+			return err
+		}
+		if apiKey == nil {
+			return httpserver.NewHTTPError(http.StatusUnauthorized, "invalid api key")
+		}
+		principal := &httpserver.Principal{ID: apiKey.ID, Method: "apikey", Scopes: apiKey.Scopes, Attributes: apiKey.Attributes}
+		for _, scope := range cfg.Scopes {
+			if !principal.HasScope(scope) {
+				return httpserver.NewHTTPError(http.StatusForbidden, "missing scope "+scope)
+			}
+		}
+		c.SetPrincipal(principal)
+		return c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"github.com/textthree/cvgoweb"
+	"net/http"
+)
+
+// 要求调用方拥有全部 scopes，放在鉴权中间件之后，未鉴权返回 401，缺少授权返回 403
+func RequireScopes(scopes ...string) httpserver.MiddlewareHandler {
+	return func(c *httpserver.Context) error {
+		principal := c.Principal()
+		if principal == nil {
+			return httpserver.NewHTTPError(http.StatusUnauthorized)
+		}
+		for _, scope := range scopes {
+			if !principal.HasScope(scope) {
+				return httpserver.NewHTTPError(http.StatusForbidden, "missing scope "+scope)
+			}
+		}
+		return c.Next()
+	}
+}
+
+// 常量时间比较，先取摘要使耗时与长度无关
+func secureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"github.com/textthree/cvgoweb"
+	"net/http"
+	"strconv"
+)
+
+// Basic 鉴权查到的用户
+type BasicUser struct {
+	// 明文密码，或配合 BasicAuthConfig.Compare 使用的密码哈希
+	Password string
+	// 授权范围
+	Scopes []string
+}
+
+type BasicAuthConfig struct {
+	// 默认 Restricted
+	Realm string
+	// 按用户名查找用户，用户不存在时返回 nil, nil
+	Lookup func(c *httpserver.Context, username string) (*BasicUser, error)
+	// 比较密码，默认按明文做常量时间比较，保存哈希时替换为 bcrypt 等的比较函数
+	Compare func(stored, password string) bool
+}
+
+// 固定的用户名和明文密码
+func BasicUsers(users map[string]string) func(c *httpserver.Context, username string) (*BasicUser, error) {
+	return func(c *httpserver.Context, username string) (*BasicUser, error) {
+		password, ok := users[username]
+		if !ok {
+			return nil, nil
+		}
+		return &BasicUser{Password: password}, nil
+	}
+}
+
+// HTTP Basic 鉴权，通过后 ctx.Principal() 为该用户，Method 为 basic
+//
+//	engine.Prefix("/admin").UseMiddleware(middleware.BasicAuth(middleware.BasicAuthConfig{Lookup: middleware.BasicUsers(map[string]string{"ops": "secret"})}))
+func BasicAuth(cfg BasicAuthConfig) httpserver.MiddlewareHandler {
+	if cfg.Lookup == nil {
+		panic("basic auth middleware: Lookup is required")
+	}
+	if cfg.Realm == "" {
+		cfg.Realm = "Restricted"
+	}
+	if cfg.Compare == nil {
+		cfg.Compare = secureCompare
+	}
+	challenge := "Basic realm=" + strconv.Quote(cfg.Realm) + `, charset="UTF-8"`
+
+	return func(c *httpserver.Context) error {
+		username, password, ok := c.Request().BasicAuth()
+		if !ok {
+			c.Resp.SetHeader("WWW-Authenticate", challenge)
+			return httpserver.NewHTTPError(http.StatusUnauthorized)
+		}
+		user, err := cfg.Lookup(c, username)
+		if err != nil {
+			return err
+		}
+		// 用户不存在时也做一次比较，避免通过耗时判断用户名是否存在
+		stored := ""
+		if user != nil {
+			stored = user.Password
+		}
+		if !cfg.Compare(stored, password) || user == nil {
+			c.Resp.SetHeader("WWW-Authenticate", challenge)
+			return httpserver.NewHTTPError(http.StatusUnauthorized)
+		}
+		c.SetPrincipal(&httpserver.Principal{ID: username, Method: "basic", Scopes: user.Scopes})
+		return c.Next()
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/textthree/cvgoweb"
+)
+
+// 限制请求 body 大小（单位：byte），超出时返回 413
+// 挂在路由或分组上可以覆盖全局的 http.bodyLimit 配置，size 为 0 表示不限制
+func BodyLimit(size int64) httpserver.MiddlewareHandler {
+	return func(ctx *httpserver.Context) error {
+		if err := ctx.SetBodyLimit(size); err != nil {
+			return err
+		}
+		return ctx.Next()
+	}
+}
@@ -14,14 +14,14 @@ func Cost() httpserver.MiddlewareHandler {
 		// 记录开始时间
 		start := time.Now()
 
-		// 使用next执行具体的业务逻辑
-		c.Next()
+		// 使用next执行具体的业务逻辑，错误交回给调用链
+		err := c.Next()
 
 		// 记录结束时间
 		end := time.Now()
 		cost := end.Sub(start)
 		log.Printf("api uri: %v, cost: %v", c.Request().RequestURI, cost.Seconds())
 
-		return nil
+		return err
 	}
 }
@@ -0,0 +1,268 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/textthree/cvgoweb"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CSRF token 的保存方式
+type CSRFMode int
+
+const (
+	// 双重提交：token 保存在 cookie 中，请求时在 header 或表单中再提交一次，不需要服务端状态
+	CSRFDoubleSubmit CSRFMode = iota
+	// 同步器 token：token 保存在 session 中，需要先调用 engine.EnableSessions
+	CSRFSynchronizer
+)
+
+const (
+	csrfTokenLength = 32
+	csrfSessionKey  = "_csrf"
+	// ctx.SetVal 中保存本次请求 token 的 key
+	CSRFTokenKey = "csrfToken"
+)
+
+type CSRFConfig struct {
+	Mode CSRFMode
+	// 双重提交模式的 cookie 名称，默认 csrf_token
+	CookieName   string
+	CookiePath   string
+	CookieDomain string
+	// cookie 有效期，0 表示浏览器会话 cookie
+	CookieMaxAge time.Duration
+	// 为 true 时 cookie 只通过 https 发送，https 请求总是设置 Secure
+	Secure bool
+	// 默认 Lax
+	SameSite http.SameSite
+	// 提交 token 的请求头，默认 X-CSRF-Token，每个响应也会通过这个头返回 token
+	HeaderName string
+	// 提交 token 的表单字段，默认 _csrf。multipart 表单中必须是第一个字段，否则需要通过请求头提交
+	FormField string
+	// 除本站外允许的 Origin，如 https://admin.example.com
+	TrustedOrigins []string
+	// 不校验的路由，匹配 ctx.RoutePattern()，以 * 结尾时按前缀匹配，如 "/api/*"
+	Exempt []string
+	// 返回 true 时不校验，如使用 Authorization 头鉴权的接口
+	Skip func(c *httpserver.Context) bool
+}
+
+// CSRF 防护，GET、HEAD、OPTIONS、TRACE 之外的请求需要提交 token，并校验 Origin（没有时校验 https 请求的 Referer），
+// 不通过时返回 403。页面模版通过 CSRFToken、CSRFField 取得 token，前端脚本可以读取响应头 X-CSRF-Token
+//
+//	engine.UseMiddleware(middleware.CSRF(middleware.CSRFConfig{Exempt: []string{"/api/*"}}))
+//	c.Resp.Html("form.html", map[string]interface{}{"csrfField": middleware.CSRFField(c)})
+func CSRF(cfg CSRFConfig) httpserver.MiddlewareHandler {
+	if cfg.CookieName == "" {
+		cfg.CookieName = "csrf_token"
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "X-CSRF-Token"
+	}
+	if cfg.FormField == "" {
+		cfg.FormField = "_csrf"
+	}
+	trusted := map[string]bool{}
+	for _, origin := range cfg.TrustedOrigins {
+		trusted[strings.ToLower(strings.TrimRight(origin, "/"))] = true
+	}
+
+	return func(c *httpserver.Context) error {
+		if (cfg.Skip != nil && cfg.Skip(c)) || csrfExempt(cfg.Exempt, c.RoutePattern()) {
+			return c.Next()
+		}
+		secret := csrfSecret(c, cfg)
+		if !csrfSafeMethod(c.Request().Method) {
+			if !csrfOriginAllowed(c, trusted) {
+				return httpserver.NewHTTPError(http.StatusForbidden, "csrf: origin not allowed")
+			}
+			if secret == nil || !csrfTokenValid(secret, csrfSubmittedToken(c, cfg)) {
+				return httpserver.NewHTTPError(http.StatusForbidden, "csrf: invalid token")
+			}
+		}
+		if secret == nil {
+			secret = newCSRFSecret(c, cfg)
+		}
+		token := maskCSRFToken(secret)
+		c.SetVal(CSRFTokenKey, token)
+		c.Resp.SetHeader(cfg.HeaderName, token)
+		c.GetResponse().Header().Add("Vary", "Cookie")
+		return c.Next()
+	}
+}
+
+func csrfExempt(patterns []string, route string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(route, prefix) {
+				return true
+			}
+		} else if route == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// 读取已有的 token，没有或格式不对时返回 nil
+func csrfSecret(c *httpserver.Context, cfg CSRFConfig) []byte {
+	var encoded string
+	if cfg.Mode == CSRFSynchronizer {
+		encoded = c.Session().Get(csrfSessionKey).ToString()
+	} else if cookie, err := c.Request().Cookie(cfg.CookieName); err == nil {
+		encoded = cookie.Value
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(secret) != csrfTokenLength {
+		return nil
+	}
+	return secret
+}
+
+func newCSRFSecret(c *httpserver.Context, cfg CSRFConfig) []byte {
+	secret := make([]byte, csrfTokenLength)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	if cfg.Mode == CSRFSynchronizer {
+		c.Session().Set(csrfSessionKey, encoded)
+		return secret
+	}
+	// 前端脚本需要读取，不设置 HttpOnly
+	c.Resp.SetHttpCookie(&http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    encoded,
+		Path:     cfg.CookiePath,
+		Domain:   cfg.CookieDomain,
+		MaxAge:   int(cfg.CookieMaxAge.Seconds()),
+		Secure:   cfg.Secure || c.Scheme() == "https",
+		SameSite: cfg.SameSite,
+	})
+	return secret
+}
+
+// 每次输出的 token 都用随机数异或，防止 BREACH 类攻击从压缩后的页面中推测 token
+func maskCSRFToken(secret []byte) string {
+	masked := make([]byte, 2*csrfTokenLength)
+	if _, err := rand.Read(masked[:csrfTokenLength]); err != nil {
+		panic(err)
+	}
+	for i := range secret {
+		masked[csrfTokenLength+i] = secret[i] ^ masked[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+func csrfTokenValid(secret []byte, token string) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(masked) != 2*csrfTokenLength {
+		return false
+	}
+	unmasked := make([]byte, csrfTokenLength)
+	for i := range unmasked {
+		unmasked[i] = masked[i] ^ masked[csrfTokenLength+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
+
+// multipart 表单中最多预读这么多字节查找 token
+const csrfMultipartPeek = 4 << 10
+
+// 先取请求头，再取表单字段
+func csrfSubmittedToken(c *httpserver.Context, cfg CSRFConfig) string {
+	if token := c.Request().Header.Get(cfg.HeaderName); token != "" {
+		return token
+	}
+	mediaType, params, err := mime.ParseMediaType(c.Request().Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		return c.Request().PostFormValue(cfg.FormField)
+	case "multipart/form-data":
+		return csrfMultipartToken(c, cfg, params["boundary"])
+	}
+	return ""
+}
+
+// 不解析整个 multipart body，避免绕过上传限制、影响 StreamMultipart，
+// 只预读开头的一小段取第一个字段，读取的内容放回 body
+func csrfMultipartToken(c *httpserver.Context, cfg CSRFConfig, boundary string) string {
+	request := c.Request()
+	if boundary == "" || request.Body == nil || request.Body == http.NoBody {
+		return ""
+	}
+	head, err := io.ReadAll(io.LimitReader(request.Body, csrfMultipartPeek))
+	c.ResetBody(io.MultiReader(bytes.NewReader(head), request.Body))
+	if err != nil {
+		return ""
+	}
+	part, err := multipart.NewReader(bytes.NewReader(head), boundary).NextPart()
+	if err != nil || part.FormName() != cfg.FormField {
+		return ""
+	}
+	value, err := io.ReadAll(part)
+	if err != nil {
+		return ""
+	}
+	return string(value)
+}
+
+// 有 Origin 时必须是本站或受信任的来源；没有 Origin 的 https 请求校验 Referer，两者都没有时放行（由 token 保证）
+func csrfOriginAllowed(c *httpserver.Context, trusted map[string]bool) bool {
+	self := strings.ToLower(c.Scheme() + "://" + c.Host())
+	origin := c.Request().Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		referer := c.Request().Referer()
+		if referer == "" || c.Scheme() != "https" {
+			return origin == ""
+		}
+		u, err := url.Parse(referer)
+		if err != nil {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	origin = strings.ToLower(strings.TrimRight(origin, "/"))
+	return origin == self || trusted[origin]
+}
+
+// 本次请求的 token，放入页面模版或 meta 标签
+func CSRFToken(c *httpserver.Context) string {
+	return c.GetVal(CSRFTokenKey).ToString()
+}
+
+// 包含 token 的隐藏表单字段，field 默认 _csrf，在模版中直接输出 {{ .csrfField }}
+func CSRFField(c *httpserver.Context, field ...string) template.HTML {
+	name := "_csrf"
+	if len(field) > 0 && field[0] != "" {
+		name = field[0]
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(name) + `" value="` + template.HTMLEscapeString(CSRFToken(c)) + `">`)
+}
@@ -38,7 +38,6 @@ func I18n() httpserver.MiddlewareHandler {
 				provider.Clog().Error("语言包不存在:", languagePkg)
 			}
 		}
-		ctx.Next()
-		return nil
+		return ctx.Next()
 	}
 }
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/textthree/cvgoweb"
+	"github.com/textthree/cvgoweb/jwt"
+	"net/http"
+	"strings"
+)
+
+type JWTConfig struct {
+	jwt.Verifier
+	// token 的来源，按顺序查找，格式为 "header:<name>"、"cookie:<name>" 或 "query:<name>"，
+	// Authorization 头会去掉 Bearer 前缀，默认 ["header:Authorization"]
+	TokenLookup []string
+	// 为 true 时没有携带 token 的请求也放行，携带了无效 token 仍然返回 401，用于登录后可见更多内容的接口
+	Optional bool
+	// 把 sub 写入 ctx.SetVal 的 key，供 KeyByUser 等按用户处理的中间件使用，默认 userId，"-" 表示不写入
+	UserValKey string
+}
+
+type tokenExtractor func(c *httpserver.Context) string
+
+// JWT 鉴权，校验通过后 token 放在 Context 中，ctx.Principal() 的 ID 为 sub，Scopes 取自 scope 或 scp 声明
+// 控制器中通过 middleware.JWTToken(c) 取出 token，或者用 middleware.JWTClaims[UserClaims](c) 解析为自定义声明
+//
+//	api := engine.Prefix("/api")
+//	api.UseMiddleware(middleware.JWT(middleware.JWTConfig{
+//		Verifier: jwt.Verifier{Keys: jwt.NewJWKS("https://auth.example.com/.well-known/jwks.json"), Issuer: "https://auth.example.com", Leeway: time.Minute},
+//	}))
+func JWT(cfg JWTConfig) httpserver.MiddlewareHandler {
+	if cfg.Keys == nil {
+		panic("jwt middleware: Keys is required")
+	}
+	if len(cfg.TokenLookup) == 0 {
+		cfg.TokenLookup = []string{"header:Authorization"}
+	}
+	if cfg.UserValKey == "" {
+		cfg.UserValKey = "userId"
+	}
+	extractors := make([]tokenExtractor, 0, len(cfg.TokenLookup))
+	for _, lookup := range cfg.TokenLookup {
+		extractors = append(extractors, newTokenExtractor(lookup))
+	}
+	verifier := cfg.Verifier
+
+	return func(c *httpserver.Context) error {
+		var raw string
+		for _, extract := range extractors {
+			if raw = extract(c); raw != "" {
+				break
+			}
+		}
+		if raw == "" {
+			if cfg.Optional {
+				return c.Next()
+			}
+			c.Resp.SetHeader("WWW-Authenticate", `Bearer`)
+			return httpserver.NewHTTPError(http.StatusUnauthorized, "missing token")
+		}
+		token, err := verifier.Verify(c, raw)
+		if err != nil {
+			// 密钥加载失败等服务端错误不是 token 的问题
+			if !isTokenError(err) {
+				return err
+			}
+			c.Resp.SetHeader("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, err.Error()))
+			return httpserver.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		c.SetBaseContext(jwt.ContextWithToken(c.BaseContext(), token))
+		c.SetPrincipal(&httpserver.Principal{ID: token.Claims.Subject, Method: "jwt", Scopes: tokenScopes(token)})
+		if cfg.UserValKey != "-" && token.Claims.Subject != "" {
+			c.SetVal(cfg.UserValKey, token.Claims.Subject)
+		}
+		return c.Next()
+	}
+}
+
+func newTokenExtractor(lookup string) tokenExtractor {
+	source, name, ok := strings.Cut(lookup, ":")
+	if !ok || name == "" {
+		panic(fmt.Sprintf("invalid token lookup %q", lookup))
+	}
+	switch source {
+	case "header":
+		return func(c *httpserver.Context) string {
+			value := c.Request().Header.Get(name)
+			if strings.EqualFold(name, "Authorization") {
+				if len(value) < 7 || !strings.EqualFold(value[:7], "Bearer ") {
+					return ""
+				}
+				value = value[7:]
+			}
+			return strings.TrimSpace(value)
+		}
+	case "cookie":
+		return func(c *httpserver.Context) string {
+			cookie, err := c.Request().Cookie(name)
+			if err != nil {
+				return ""
+			}
+			return cookie.Value
+		}
+	case "query":
+		return func(c *httpserver.Context) string {
+			return c.Request().URL.Query().Get(name)
+		}
+	}
+	panic(fmt.Sprintf("invalid token lookup %q", lookup))
+}
+
+// scope 为空格分隔的字符串（RFC 8693），scp 为字符串或数组
+func tokenScopes(token *jwt.Token) []string {
+	var claims struct {
+		Scope string          `json:"scope"`
+		Scp   json.RawMessage `json:"scp"`
+	}
+	if token.Decode(&claims) != nil {
+		return nil
+	}
+	if claims.Scope != "" {
+		return strings.Fields(claims.Scope)
+	}
+	var scp jwt.Audience
+	if len(claims.Scp) > 0 && json.Unmarshal(claims.Scp, &scp) == nil {
+		if len(scp) == 1 {
+			return strings.Fields(scp[0])
+		}
+		return scp
+	}
+	return nil
+}
+
+var tokenErrors = []error{
+	jwt.ErrTokenMalformed, jwt.ErrAlgorithm, jwt.ErrKeyNotFound, jwt.ErrSignature,
+	jwt.ErrTokenExpired, jwt.ErrTokenNotValidYet, jwt.ErrIssuer, jwt.ErrAudience,
+}
+
+func isTokenError(err error) bool {
+	for _, target := range tokenErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// 取出 JWT 中间件校验通过的 token，没有时返回 nil
+func JWTToken(c *httpserver.Context) *jwt.Token {
+	return jwt.TokenFromContext(c)
+}
+
+// 把 token 的载荷解析为自定义声明
+//
+//	claims, err := middleware.JWTClaims[UserClaims](c)
+func JWTClaims[T any](c *httpserver.Context) (*T, error) {
+	token := JWTToken(c)
+	if token == nil {
+		return nil, errors.New("jwt: no token in context")
+	}
+	claims := new(T)
+	if err := token.Decode(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
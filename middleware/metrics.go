@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"github.com/textthree/cvgoweb"
+	"github.com/textthree/cvgoweb/metrics"
+	"strconv"
+	"time"
+)
+
+// 按路由统计请求数、延迟、处理中的请求数和响应大小（RED 指标）
+// 标签使用路由模式（如 /user/:id）而不是原始路径，避免时间序列数量失控
+// 应作为第一个全局中间件，registry 为空时使用 metrics.DefaultRegistry
+func Metrics(registry ...*metrics.Registry) httpserver.MiddlewareHandler {
+	reg := metrics.DefaultRegistry
+	if len(registry) > 0 && registry[0] != nil {
+		reg = registry[0]
+	}
+	requests := reg.Counter("http_requests_total", "Total number of HTTP requests.", "method", "route", "status")
+	duration := reg.Histogram("http_request_duration_seconds", "HTTP request latency in seconds.", metrics.DefBuckets, "method", "route", "status")
+	inFlight := reg.Gauge("http_requests_in_flight", "Number of HTTP requests currently being served.", "method", "route")
+	size := reg.Histogram("http_response_size_bytes", "HTTP response body size in bytes.",
+		[]float64{100, 1000, 10000, 100000, 1000000, 10000000}, "method", "route", "status")
+
+	return func(c *httpserver.Context) error {
+		method := c.Request().Method
+		route := c.RoutePattern()
+		gauge := inFlight.With(method, route)
+		gauge.Inc()
+		start := time.Now()
+		defer func() {
+			gauge.Dec()
+			status := statusClass(c.Resp.Status())
+			requests.With(method, route, status).Inc()
+			duration.With(method, route, status).Observe(time.Since(start).Seconds())
+			size.With(method, route, status).Observe(float64(c.Resp.Size()))
+		}()
+		// 错误在这里交给错误处理函数输出，才能统计到最终的状态码
+		if err := c.Next(); err != nil {
+			c.Error(err)
+		}
+		return nil
+	}
+}
+
+// 输出 Prometheus 文本格式的指标，注册为路由使用：engine.Get("/metrics", middleware.MetricsHandler())
+func MetricsHandler(registry ...*metrics.Registry) httpserver.RequestHandler {
+	reg := metrics.DefaultRegistry
+	if len(registry) > 0 && registry[0] != nil {
+		reg = registry[0]
+	}
+	return func(c *httpserver.Context) {
+		reg.ServeHTTP(c.GetResponse(), c.Request())
+	}
+}
+
+// 200 -> 2xx
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
@@ -0,0 +1,387 @@
+package middleware
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"github.com/textthree/cvgoweb"
+	"github.com/textthree/provider"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 限流算法
+type RateLimitAlgorithm int
+
+const (
+	// 令牌桶：按 Limit/Window 的速率补充令牌，允许最多 Burst 个请求的突发
+	TokenBucket RateLimitAlgorithm = iota
+	// 滑动窗口：按当前窗口和上一个窗口的加权计数估算最近 Window 内的请求数
+	SlidingWindow
+)
+
+// 限流规则
+type RateLimitRule struct {
+	Algorithm RateLimitAlgorithm
+	// Window 内允许的请求数
+	Limit  int
+	Window time.Duration
+	// 令牌桶容量，0 表示等于 Limit，滑动窗口忽略此项
+	Burst int
+}
+
+// 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// 配额完全恢复（令牌桶填满或窗口结束）还需的时间
+	Reset time.Duration
+	// 被拒绝时，下一个请求可以通过还需的时间
+	RetryAfter time.Duration
+}
+
+// 限流状态存储，多实例部署时使用 Redis 等共享存储，见 NewRedisRateLimitStore
+type RateLimitStore interface {
+	// 为 key 消耗一次配额
+	Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+// 限流的维度，返回空字符串时不限流
+type RateLimitKeyFunc func(c *httpserver.Context) string
+
+// 按客户端 IP 限流，IP 的解析受 http.trustedProxies 配置影响
+func KeyByIP() RateLimitKeyFunc {
+	return func(c *httpserver.Context) string {
+		return "ip:" + c.ClientIp()
+	}
+}
+
+// 按已登录用户限流，优先使用鉴权中间件设置的 ctx.Principal()，
+// 其次是通过 ctx.SetVal 写入的用户标识，valKey 默认 userId，都没有时按 IP 限流
+func KeyByUser(valKey ...string) RateLimitKeyFunc {
+	key := "userId"
+	if len(valKey) > 0 && valKey[0] != "" {
+		key = valKey[0]
+	}
+	return func(c *httpserver.Context) string {
+		if principal := c.Principal(); principal != nil && principal.ID != "" {
+			return "user:" + principal.Method + ":" + principal.ID
+		}
+		if user := c.GetVal(key).ToString(); user != "" {
+			return "user:" + user
+		}
+		return "ip:" + c.ClientIp()
+	}
+}
+
+// 按 API Key 限流，需要挂在 APIKeyAuth 之后，使用校验通过的 Key ID，没有时按 IP 限流。
+// 不使用请求头中的原始值，否则客户端随意更换 key 就能绕过限流，密钥明文也会被当作存储的 key
+func KeyByAPIKey() RateLimitKeyFunc {
+	return func(c *httpserver.Context) string {
+		if principal := c.Principal(); principal != nil && principal.Method == "apikey" && principal.ID != "" {
+			return "key:" + principal.ID
+		}
+		return "ip:" + c.ClientIp()
+	}
+}
+
+type RateLimitConfig struct {
+	// 默认规则
+	RateLimitRule
+	// 按路由覆盖默认规则，key 为 "GET /user/:id" 或 "/user/:id"（不区分方法），
+	// 路由级规则的计数与默认规则相互独立
+	Routes map[string]RateLimitRule
+	// 限流维度，默认 KeyByIP
+	Key RateLimitKeyFunc
+	// 默认 NewMemoryRateLimitStore()
+	Store RateLimitStore
+	// 返回 true 时跳过限流，如内网地址、健康检查
+	Skip func(c *httpserver.Context) bool
+	// 存储出错时拒绝请求（返回 503），默认放行并记录日志
+	FailClosed bool
+}
+
+// 限流中间件，超出限制时返回 429 并设置 Retry-After。
+// 响应头按 IETF RateLimit 头字段草案输出 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset（秒）和 RateLimit-Policy
+//
+//	engine.UseMiddleware(middleware.RateLimit(middleware.RateLimitConfig{
+//		RateLimitRule: middleware.RateLimitRule{Limit: 100, Window: time.Minute},
+//		Routes:        map[string]middleware.RateLimitRule{"POST /login": {Algorithm: middleware.SlidingWindow, Limit: 5, Window: time.Minute}},
+//	}))
+func RateLimit(cfg RateLimitConfig) httpserver.MiddlewareHandler {
+	checkRateLimitRule("default", cfg.RateLimitRule)
+	for name, rule := range cfg.Routes {
+		checkRateLimitRule(name, rule)
+	}
+	if cfg.Key == nil {
+		cfg.Key = KeyByIP()
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+
+	return func(c *httpserver.Context) error {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			return c.Next()
+		}
+		key := cfg.Key(c)
+		if key == "" {
+			return c.Next()
+		}
+		rule, scope := cfg.RateLimitRule, ""
+		if len(cfg.Routes) > 0 {
+			route := c.RoutePattern()
+			if r, ok := cfg.Routes[c.Request().Method+" "+route]; ok {
+				rule, scope = r, c.Request().Method+" "+route
+			} else if r, ok := cfg.Routes[route]; ok {
+				rule, scope = r, route
+			}
+		}
+		result, err := cfg.Store.Take(c, rateLimitStoreKey(rule, scope, key), rule)
+		if err != nil {
+			if cfg.FailClosed {
+				return httpserver.NewHTTPError(http.StatusServiceUnavailable)
+			}
+			provider.Clog().Error("rate limit store:", err)
+			return c.Next()
+		}
+		c.Resp.SetHeader("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Resp.SetHeader("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Resp.SetHeader("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		c.Resp.SetHeader("RateLimit-Policy", rateLimitPolicy(rule))
+		if !result.Allowed {
+			c.Resp.SetHeader("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+			return httpserver.NewHTTPError(http.StatusTooManyRequests)
+		}
+		return c.Next()
+	}
+}
+
+func checkRateLimitRule(name string, rule RateLimitRule) {
+	if rule.Limit <= 0 || rule.Window <= 0 {
+		panic(fmt.Sprintf("rate limit %s: Limit and Window must be positive", name))
+	}
+	if rule.Algorithm != TokenBucket && rule.Algorithm != SlidingWindow {
+		panic(fmt.Sprintf("rate limit %s: unknown algorithm %d", name, rule.Algorithm))
+	}
+}
+
+// 不同算法、不同路由规则的计数互不影响
+func rateLimitStoreKey(rule RateLimitRule, scope, key string) string {
+	prefix := "tb"
+	if rule.Algorithm == SlidingWindow {
+		prefix = "sw"
+	}
+	if scope != "" {
+		return prefix + ":" + scope + ":" + key
+	}
+	return prefix + ":" + key
+}
+
+func rateLimitPolicy(rule RateLimitRule) string {
+	policy := strconv.Itoa(rule.Limit) + ";w=" + strconv.Itoa(ceilSeconds(rule.Window))
+	if rule.Algorithm == TokenBucket && rule.Burst > 0 {
+		policy += ";burst=" + strconv.Itoa(rule.Burst)
+	}
+	return policy
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+// 进程内的限流存储，只适合单实例部署
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	entries map[string]*rateLimitEntry
+	// 按过期时间排序的小顶堆，清理和淘汰都从堆顶取，不需要遍历所有 key
+	expiry  rateLimitHeap
+	maxKeys int
+	now     func() time.Time
+}
+
+type rateLimitEntry struct {
+	key   string
+	index int // 在 expiry 中的下标
+	// 令牌桶：剩余令牌和上次补充的时间
+	tokens float64
+	last   time.Time
+	// 滑动窗口：当前窗口的开始时间和前后两个窗口的计数
+	start    time.Time
+	prev     int
+	curr     int
+	expireAt time.Time
+}
+
+// maxKeys 为最多保存的 key 数量，默认 100000，超出时先清理过期的 key，仍然超出则淘汰最早过期的 key
+func NewMemoryRateLimitStore(maxKeys ...int) *MemoryRateLimitStore {
+	store := &MemoryRateLimitStore{entries: map[string]*rateLimitEntry{}, maxKeys: 100000, now: time.Now}
+	if len(maxKeys) > 0 && maxKeys[0] > 0 {
+		store.maxKeys = maxKeys[0]
+	}
+	return store
+}
+
+func (self *MemoryRateLimitStore) Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	now := self.now()
+	self.sweep(now)
+	entry, ok := self.entries[key]
+	if !ok {
+		// 数量达到上限时淘汰最早过期的 key
+		if len(self.entries) >= self.maxKeys {
+			self.remove(self.expiry[0])
+		}
+		entry = &rateLimitEntry{key: key}
+		if rule.Algorithm == TokenBucket {
+			entry.tokens = float64(rateLimitBurst(rule))
+			entry.last = now
+		}
+	}
+	var result RateLimitResult
+	if rule.Algorithm == SlidingWindow {
+		result = entry.slidingWindow(rule, now)
+	} else {
+		result = entry.tokenBucket(rule, now)
+	}
+	if ok {
+		heap.Fix(&self.expiry, entry.index)
+	} else {
+		self.entries[key] = entry
+		heap.Push(&self.expiry, entry)
+	}
+	return result, nil
+}
+
+// 当前保存的 key 数量
+func (self *MemoryRateLimitStore) Len() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.entries)
+}
+
+// 清理已过期的 key
+func (self *MemoryRateLimitStore) sweep(now time.Time) {
+	for len(self.expiry) > 0 && !self.expiry[0].expireAt.After(now) {
+		self.remove(self.expiry[0])
+	}
+}
+
+func (self *MemoryRateLimitStore) remove(entry *rateLimitEntry) {
+	heap.Remove(&self.expiry, entry.index)
+	delete(self.entries, entry.key)
+}
+
+// 实现 container/heap 接口
+type rateLimitHeap []*rateLimitEntry
+
+func (self rateLimitHeap) Len() int {
+	return len(self)
+}
+
+func (self rateLimitHeap) Less(i, j int) bool {
+	return self[i].expireAt.Before(self[j].expireAt)
+}
+
+func (self rateLimitHeap) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+	self[i].index = i
+	self[j].index = j
+}
+
+func (self *rateLimitHeap) Push(x any) {
+	entry := x.(*rateLimitEntry)
+	entry.index = len(*self)
+	*self = append(*self, entry)
+}
+
+func (self *rateLimitHeap) Pop() any {
+	old := *self
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*self = old[:len(old)-1]
+	return entry
+}
+
+func rateLimitBurst(rule RateLimitRule) int {
+	if rule.Burst > 0 {
+		return rule.Burst
+	}
+	return rule.Limit
+}
+
+func (self *rateLimitEntry) tokenBucket(rule RateLimitRule, now time.Time) RateLimitResult {
+	burst := float64(rateLimitBurst(rule))
+	// 每纳秒补充的令牌数
+	rate := float64(rule.Limit) / float64(rule.Window)
+	if elapsed := now.Sub(self.last); elapsed > 0 {
+		self.tokens = math.Min(burst, self.tokens+float64(elapsed)*rate)
+		self.last = now
+	}
+	result := RateLimitResult{Limit: int(burst)}
+	if self.tokens >= 1 {
+		self.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - self.tokens) / rate))
+	}
+	result.Remaining = int(self.tokens)
+	result.Reset = time.Duration(math.Ceil((burst - self.tokens) / rate))
+	self.expireAt = now.Add(result.Reset)
+	return result
+}
+
+func (self *rateLimitEntry) slidingWindow(rule RateLimitRule, now time.Time) RateLimitResult {
+	start := now.Truncate(rule.Window)
+	if !start.Equal(self.start) {
+		if start.Sub(self.start) == rule.Window {
+			self.prev = self.curr
+		} else {
+			self.prev = 0
+		}
+		self.curr = 0
+		self.start = start
+	}
+	elapsed := now.Sub(start)
+	estimated := slidingEstimate(self.prev, self.curr, elapsed, rule.Window)
+	result := RateLimitResult{Limit: rule.Limit, Reset: rule.Window - elapsed}
+	if estimated+1 <= float64(rule.Limit) {
+		self.curr++
+		estimated++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = slidingRetryAfter(self.prev, self.curr, elapsed, rule)
+	}
+	result.Remaining = max(rule.Limit-int(math.Ceil(estimated)), 0)
+	// 当前窗口的计数在下一个窗口结束前都会参与估算
+	self.expireAt = start.Add(2 * rule.Window)
+	return result
+}
+
+// 上一个窗口的计数按其与最近 Window 的重叠比例加权
+func slidingEstimate(prev, curr int, elapsed, window time.Duration) float64 {
+	weight := 1 - float64(elapsed)/float64(window)
+	return float64(prev)*weight + float64(curr)
+}
+
+// 等到上一个窗口的权重降到足以容纳一个请求，当前窗口已满时等到下一个窗口
+func slidingRetryAfter(prev, curr int, elapsed time.Duration, rule RateLimitRule) time.Duration {
+	free := float64(rule.Limit - curr - 1)
+	if free < 0 || prev == 0 {
+		return rule.Window - elapsed
+	}
+	weight := free / float64(prev)
+	wait := time.Duration((1-weight)*float64(rule.Window)) - elapsed
+	if wait <= 0 {
+		return time.Millisecond
+	}
+	return wait
+}
@@ -16,7 +16,7 @@ func Recovery(diyMsg map[string]interface{}) httpserver.MiddlewareHandler {
 	calback := func(c *httpserver.Context) error {
 		cfgSvc := c.Holder().NewSingle(config.Name).(config.Service)
 		if cfgSvc.IsDebug() {
-			return nil
+			return c.Next()
 		}
 		println("use recovery middleware")
 		// 捕获 c.Next() 出现的panic
@@ -61,8 +61,7 @@ func Recovery(diyMsg map[string]interface{}) httpserver.MiddlewareHandler {
 		if isAbort {
 			return errors.New("broken pipe")
 		}
-		c.Next()
-		return nil
+		return c.Next()
 
 	}
 	return calback
@@ -8,7 +8,6 @@ import (
 func RequestLog() httpserver.MiddlewareHandler {
 	return func(c *httpserver.Context) error {
 		fmt.Println("Use goodlog middleware")
-		c.Next()
-		return nil
+		return c.Next()
 	}
 }
@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 	"github.com/textthree/cvgoweb"
 	"log"
 	"time"
@@ -12,39 +11,46 @@ import (
 func Timeout(timeout time.Duration) httpserver.MiddlewareHandler {
 	// 使用回调函数，返回一个匿名函数保存到 handlers，由 context.Next() 进行调用
 	return func(ctx *httpserver.Context) error {
-		fmt.Println("use timeout middleware")
-		finish := make(chan struct{}, 1)
+		finish := make(chan error, 1)
 		panicChan := make(chan interface{}, 1)
 
 		// 执行业务逻辑前预操作：创建超时 context
 		durationCtx, cancel := context.WithTimeout(ctx.BaseContext(), timeout)
 		defer cancel()
 
-		go func() {
+		// 超时后业务 Goroutine 可能还在运行，请求结束时的 session 保存、临时文件清理会等它结束
+		ctx.Go(func() {
 			defer func() {
 				if p := recover(); p != nil {
 					panicChan <- p
 				}
 			}()
-			// 继续往下执行中间件或业务逻辑
-			ctx.Next()
-			finish <- struct{}{}
-		}()
+			// 继续往下执行中间件或业务逻辑，返回的错误（413、401 等）交回给调用链
+			finish <- ctx.Next()
+		})
 		// 信号监听
 		select {
 		case err := <-panicChan:
 			//ctx.Json(500, err)
 			panic(err)
 		case <-durationCtx.Done():
-			// 业务处理超时
+			// 事件流等长连接不受超时限制，一直等到业务结束，业务 Goroutine 不能在请求结束后继续写响应
+			if ctx.IsStreaming() {
+				select {
+				case err := <-panicChan:
+					panic(err)
+				case err := <-finish:
+					return err
+				}
+			}
+			// 业务处理超时，标记超时并写出 500，之后业务 Goroutine 的写入都会被丢弃
 			log.Println("超时")
-			ctx.WriterMux().Lock()
-			defer ctx.WriterMux().Unlock()
-			ctx.Resp.SetStatus(500).Json("time out")
-			ctx.SetHasTimeout()
-		case <-finish:
+			ctx.WriteTimeout(func(resp httpserver.IResponse) {
+				resp.SetStatus(500).Json("time out")
+			})
+		case err := <-finish:
 			// 业务正常处理完毕
-			fmt.Println("finish")
+			return err
 		}
 		return nil
 	}
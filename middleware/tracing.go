@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"github.com/textthree/cvgoweb"
+	"github.com/textthree/cvgoweb/tracing"
+	"net/http"
+)
+
+// 链路追踪，每个请求创建一个 server span，名称为 "方法 路由模式"，如 "GET /user/:id"
+// 请求头中有 traceparent 时延续上游的链路。span 放在 Context 中，
+// 把 Context 作为 context.Context 传给下游调用即可继续这条链路，调用其他服务时用 tracing.Inject 写入请求头
+func Tracing(tracer *tracing.Tracer) httpserver.MiddlewareHandler {
+	return func(c *httpserver.Context) error {
+		request := c.Request()
+		route := c.RoutePattern()
+		ctx, span := tracer.Start(c.BaseContext(), request.Method+" "+route, tracing.StartOptions{
+			Kind:   tracing.SpanKindServer,
+			Parent: tracing.Extract(request.Header),
+			Attributes: map[string]interface{}{
+				"http.request.method": request.Method,
+				"http.route":          route,
+				"url.path":            request.URL.Path,
+				"url.scheme":          c.Scheme(),
+				"server.address":      c.Host(),
+				"client.address":      c.ClientIp(),
+				"user_agent.original": request.UserAgent(),
+			},
+		})
+		c.SetBaseContext(ctx)
+		defer span.End()
+
+		// 错误在这里交给错误处理函数输出，才能记录最终的状态码
+		err := c.Next()
+		if err != nil {
+			span.RecordError(err)
+			c.Error(err)
+		}
+		status := c.Resp.Status()
+		span.SetAttribute("http.response.status_code", status)
+		span.SetAttribute("http.response.body.size", c.Resp.Size())
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(status))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,186 @@
+// 内容协商：根据 Accept、Accept-Language、Accept-Encoding 选择输出格式
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	MIMEJSON    = "application/json"
+	MIMEXML     = "application/xml"
+	MIMEXML2    = "text/xml"
+	MIMEHTML    = "text/html"
+	MIMEText    = "text/plain"
+	MIMEYAML    = "application/yaml"
+	MIMEYAML2   = "application/x-yaml"
+	MIMEMsgPack = "application/msgpack"
+	// 旧版本客户端使用的 msgpack 类型
+	MIMEMsgPack2 = "application/x-msgpack"
+	MIMEProtoBuf = "application/x-protobuf"
+	MIMECSV      = "text/csv"
+)
+
+// 协商输出的数据
+type Negotiation struct {
+	Offered  []string    // 可以输出的类型，按服务端偏好排序，为空时使用 JSON、XML、YAML、MsgPack、HTML、Text，其他类型需要先 RegisterRenderer
+	Data     interface{} // 输出的数据
+	HtmlFile string      // 输出 html 时使用的模板文件，为空时 html 不参与协商
+}
+
+var defaultOffered = []string{MIMEJSON, MIMEXML, MIMEYAML, MIMEMsgPack, MIMEHTML, MIMEText}
+
+// 根据 Accept 头选择输出格式，没有可接受的格式时返回 406
+//
+//	ctx.Negotiate(200, httpserver.Negotiation{Data: user})
+func (ctx *Context) Negotiate(status int, negotiation Negotiation) {
+	offered := negotiation.Offered
+	if len(offered) == 0 {
+		offered = defaultOffered
+	}
+	if negotiation.HtmlFile == "" {
+		offered = removeOffer(offered, MIMEHTML)
+	}
+	accepted := ctx.Accepts(offered...)
+	if accepted == "" {
+		ctx.Error(NewHTTPError(http.StatusNotAcceptable))
+		return
+	}
+	if accepted == MIMEHTML {
+		ctx.Resp.SetStatus(status).Html(negotiation.HtmlFile, negotiation.Data)
+		return
+	}
+	factory, ok := rendererFor(accepted)
+	if !ok {
+		ctx.Error(fmt.Errorf("negotiate: no renderer for %s", accepted))
+		return
+	}
+	ctx.Resp.SetStatus(status).Render(factory(negotiation.Data))
+}
+
+func removeOffer(offers []string, target string) []string {
+	ret := make([]string, 0, len(offers))
+	for _, offer := range offers {
+		if offer != target {
+			ret = append(ret, offer)
+		}
+	}
+	return ret
+}
+
+// Accept 类头中的一项，如 text/html;q=0.8
+type acceptItem struct {
+	value string
+	q     float64
+}
+
+// 解析 Accept 类头，按 q 值从大到小排序，q=0 表示明确不接受，保留下来用于排除
+func parseAcceptHeader(header string) []acceptItem {
+	var items []acceptItem
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		item := acceptItem{q: 1}
+		segments := strings.Split(part, ";")
+		item.value = strings.ToLower(strings.TrimSpace(segments[0]))
+		for _, param := range segments[1:] {
+			key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(key) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+					item.q = q
+				}
+			}
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+	return items
+}
+
+// 从 offers 中选出客户端最能接受的一个，q 值相同时按 offers 顺序优先。
+// match 返回 accept 项与 offer 的匹配精确度，-1 表示不匹配，同一个 offer 使用精确度最高的项的 q 值
+func bestOffer(header string, offers []string, match func(accept, offer string) int) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+	items := parseAcceptHeader(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, item := range items {
+			if s := match(item.value, strings.ToLower(offer)); s > specificity {
+				q, specificity = item.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// type/subtype 匹配，支持 */* 和 type/*
+func matchMediaType(accept, offer string) int {
+	if i := strings.Index(accept, ";"); i >= 0 {
+		accept = accept[:i]
+	}
+	if accept == offer {
+		return 2
+	}
+	if accept == "*/*" {
+		return 0
+	}
+	if strings.HasSuffix(accept, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(accept, "*")) {
+		return 1
+	}
+	return -1
+}
+
+// 语言标签前缀匹配，如 en 匹配 en-US
+func matchLanguage(accept, offer string) int {
+	if accept == offer {
+		return 2
+	}
+	if accept == "*" {
+		return 0
+	}
+	if strings.HasPrefix(offer, accept+"-") {
+		return 1
+	}
+	return -1
+}
+
+func matchEncoding(accept, offer string) int {
+	if accept == offer {
+		return 1
+	}
+	if accept == "*" {
+		return 0
+	}
+	return -1
+}
+
+// 返回 offers 中客户端最能接受的类型，都不接受时返回空字符串
+//
+//	switch ctx.Accepts("application/json", "text/html") { ... }
+func (ctx *Context) Accepts(offers ...string) string {
+	return bestOffer(strings.Join(ctx.request.Header.Values("Accept"), ","), offers, matchMediaType)
+}
+
+func (ctx *Context) AcceptsLanguages(offers ...string) string {
+	return bestOffer(strings.Join(ctx.request.Header.Values("Accept-Language"), ","), offers, matchLanguage)
+}
+
+func (ctx *Context) AcceptsEncodings(offers ...string) string {
+	return bestOffer(strings.Join(ctx.request.Header.Values("Accept-Encoding"), ","), offers, matchEncoding)
+}
@@ -0,0 +1,32 @@
+package httpserver
+
+// 鉴权中间件识别出的调用方，Basic、API Key、HMAC 签名、JWT 等鉴权方式都写入同一个结构
+type Principal struct {
+	// 用户名、API Key 的 ID、JWT 的 sub 等
+	ID string
+	// 鉴权方式，如 basic、apikey、hmac、jwt
+	Method string
+	// 授权范围，RequireScopes 中间件据此判断
+	Scopes []string
+	// 其他附加信息
+	Attributes map[string]interface{}
+}
+
+func (self *Principal) HasScope(scope string) bool {
+	for _, item := range self.Scopes {
+		if item == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// 设置当前请求的调用方，由鉴权中间件调用
+func (ctx *Context) SetPrincipal(principal *Principal) {
+	ctx.principal = principal
+}
+
+// 当前请求的调用方，未鉴权时返回 nil
+func (ctx *Context) Principal() *Principal {
+	return ctx.principal
+}
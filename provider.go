@@ -11,6 +11,7 @@ const Name = "httpserver"
 type HttpServerProvider struct {
 	core.ServiceProvider
 	HttpServer *Engine
+	Lifecycle  []interface{} // 参与生命周期的对象，实现 StartAware、ReadyAware、ShutdownAware 中的一个或多个
 }
 
 func (self *HttpServerProvider) Name() string {
@@ -38,6 +39,9 @@ func (sp *HttpServerProvider) Params(c core.Container) []interface{} {
 	return []interface{}{c}
 }
 
-func (*HttpServerProvider) AfterInit(instance any) error {
+func (sp *HttpServerProvider) AfterInit(instance any) error {
+	if svc, ok := instance.(*HttpServerService); ok {
+		svc.RegisterLifecycle(sp.Lifecycle...)
+	}
 	return nil
 }
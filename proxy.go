@@ -0,0 +1,232 @@
+// 反向代理场景下的客户端 IP、协议、域名解析
+package httpserver
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// 设置可信代理，支持 CIDR 和单个 IP，如 "10.0.0.0/8"、"127.0.0.1"、"::1"
+// 只有直连方是可信代理时才会读取 Forwarded、X-Forwarded-* 等头
+func (self *Engine) SetTrustedProxies(proxies ...string) error {
+	nets, err := parseTrustedProxies(proxies)
+	if err != nil {
+		return err
+	}
+	self.trustedProxies = nets
+	return nil
+}
+
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy: %s", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %s", proxy)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func (req *ReqStruct) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range req.trustedProxies {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// 直连方 IP，去掉端口
+func (req *ReqStruct) remoteIp() string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(req.request.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(req.request.RemoteAddr)
+	}
+	return host
+}
+
+func (req *ReqStruct) fromTrustedProxy() bool {
+	return len(req.trustedProxies) > 0 && req.isTrustedProxy(req.remoteIp())
+}
+
+// RFC 7239 Forwarded 头中的一个元素，如 for=192.0.2.60;proto=https;host=example.com
+type forwardedElement map[string]string
+
+func parseForwarded(values []string) []forwardedElement {
+	var elements []forwardedElement
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			element := forwardedElement{}
+			for _, pair := range strings.Split(item, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				element[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(val), `"`)
+			}
+			if len(element) > 0 {
+				elements = append(elements, element)
+			}
+		}
+	}
+	return elements
+}
+
+// for 参数可能是 "[2001:db8::1]:4711"、"192.0.2.43:80"、"unknown" 或混淆标识
+func forwardedNodeIp(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	if net.ParseIP(node) == nil {
+		return ""
+	}
+	return node
+}
+
+// 代理链路，从左到右依次是客户端、经过的各级代理
+func (req *ReqStruct) forwardedChain() []string {
+	if values := req.request.Header.Values("Forwarded"); len(values) > 0 {
+		return forwardedElementChain(parseForwarded(values))
+	}
+	return req.xForwardedForChain()
+}
+
+func forwardedElementChain(elements []forwardedElement) []string {
+	chain := make([]string, 0, len(elements))
+	for _, element := range elements {
+		chain = append(chain, forwardedNodeIp(element["for"]))
+	}
+	return chain
+}
+
+func (req *ReqStruct) xForwardedForChain() []string {
+	var chain []string
+	for _, ip := range splitHeaderValues(req.request.Header.Values("X-Forwarded-For")) {
+		chain = append(chain, forwardedNodeIp(ip))
+	}
+	return chain
+}
+
+func splitHeaderValues(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+	return items
+}
+
+// 由最外层可信代理写入的元素下标：从右向左跳过可信代理，遇到第一个不可信或无法识别的节点为止。
+// 更左侧的值可能由客户端伪造，不能使用
+func (req *ReqStruct) edgeIndex(chain []string) int {
+	for i := len(chain) - 1; i > 0; i-- {
+		if chain[i] == "" || !req.isTrustedProxy(chain[i]) {
+			return i
+		}
+	}
+	return 0
+}
+
+// 客户端真实 IP：
+// 直连方不是可信代理时直接使用直连方 IP，否则从代理链路最右侧向左找到第一个不可信的 IP，
+// 防止客户端伪造 X-Forwarded-For 最左侧的值
+func (req *ReqStruct) ClientIp() string {
+	remote := req.remoteIp()
+	if !req.fromTrustedProxy() {
+		return remote
+	}
+	chain := req.forwardedChain()
+	if len(chain) == 0 {
+		if realIp := strings.TrimSpace(req.request.Header.Get("X-Real-Ip")); net.ParseIP(realIp) != nil {
+			return realIp
+		}
+		return remote
+	}
+	if ip := chain[req.edgeIndex(chain)]; ip != "" {
+		return ip
+	}
+	// unknown 或混淆标识，无法继续向左追溯
+	return remote
+}
+
+// 可信代理转发的参数，param 为 Forwarded 中的参数名，header 为对应的 X-Forwarded-* 头。
+// 与 ClientIp 一样取最外层可信代理写入的值；X-Forwarded-* 与 X-Forwarded-For 的个数不一致时，
+// 无法对应到各级代理，只使用直连代理追加的最右侧的值
+func (req *ReqStruct) forwardedParam(param, header string) string {
+	if elements := parseForwarded(req.request.Header.Values("Forwarded")); len(elements) > 0 {
+		if value := elements[req.edgeIndex(forwardedElementChain(elements))][param]; value != "" {
+			return value
+		}
+	}
+	values := splitHeaderValues(req.request.Header.Values(header))
+	if len(values) == 0 {
+		return ""
+	}
+	if chain := req.xForwardedForChain(); len(chain) == len(values) {
+		return values[req.edgeIndex(chain)]
+	}
+	return values[len(values)-1]
+}
+
+// 客户端请求使用的协议 http / https
+func (req *ReqStruct) Scheme() string {
+	if req.fromTrustedProxy() {
+		if proto := req.forwardedParam("proto", "X-Forwarded-Proto"); proto != "" {
+			return strings.ToLower(proto)
+		}
+		if strings.EqualFold(req.request.Header.Get("X-Forwarded-Ssl"), "on") {
+			return "https"
+		}
+	}
+	if req.request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// 客户端请求的域名（可能带端口）
+func (req *ReqStruct) Host() string {
+	if req.fromTrustedProxy() {
+		if host := req.forwardedParam("host", "X-Forwarded-Host"); host != "" {
+			return host
+		}
+	}
+	if req.request.Host != "" {
+		return req.request.Host
+	}
+	return req.request.URL.Host
+}
+
+// 如 https://example.com
+func (req *ReqStruct) BaseURL() string {
+	return req.Scheme() + "://" + req.Host()
+}
@@ -0,0 +1,313 @@
+// 响应渲染器
+package httpserver
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+	"html/template"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 新的输出格式实现 Renderer 后通过 ctx.Resp.Render 输出，
+// 需要参与内容协商的再用 RegisterRenderer 注册
+type Renderer interface {
+	ContentType() string
+	Render(w io.Writer) error
+}
+
+// 根据数据创建渲染器，用于内容协商
+type RendererFactory func(data interface{}) Renderer
+
+var (
+	renderersMux sync.RWMutex
+	renderers    = map[string]RendererFactory{
+		MIMEJSON:     func(data interface{}) Renderer { return JsonRender{Data: data} },
+		MIMEXML:      func(data interface{}) Renderer { return XmlRender{Data: data} },
+		MIMEXML2:     func(data interface{}) Renderer { return XmlRender{Data: data, Type: MIMEXML2} },
+		MIMEYAML:     func(data interface{}) Renderer { return YamlRender{Data: data} },
+		MIMEYAML2:    func(data interface{}) Renderer { return YamlRender{Data: data, Type: MIMEYAML2} },
+		MIMEMsgPack:  func(data interface{}) Renderer { return MsgPackRender{Data: data} },
+		MIMEMsgPack2: func(data interface{}) Renderer { return MsgPackRender{Data: data, Type: MIMEMsgPack2} },
+		MIMEText:     func(data interface{}) Renderer { return TextRender{Format: "%v", Values: []interface{}{data}} },
+		MIMEProtoBuf: func(data interface{}) Renderer { return ProtoBufRender{Data: data} },
+		MIMECSV:      func(data interface{}) Renderer { return CsvRender{Data: data} },
+	}
+)
+
+// 注册内容协商时使用的渲染器，同一类型重复注册会覆盖
+func RegisterRenderer(contentType string, factory RendererFactory) {
+	renderersMux.Lock()
+	defer renderersMux.Unlock()
+	renderers[strings.ToLower(contentType)] = factory
+}
+
+func rendererFor(contentType string) (RendererFactory, bool) {
+	renderersMux.RLock()
+	defer renderersMux.RUnlock()
+	factory, ok := renderers[strings.ToLower(contentType)]
+	return factory, ok
+}
+
+type JsonRender struct {
+	Data interface{}
+}
+
+func (r JsonRender) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (r JsonRender) Render(w io.Writer) error {
+	byt, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+type JsonpRender struct {
+	Callback string
+	Data     interface{}
+}
+
+func (r JsonpRender) ContentType() string {
+	return "application/javascript; charset=utf-8"
+}
+
+func (r JsonpRender) Render(w io.Writer) error {
+	ret, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	// 输出到前端页面的时候需要注意下进行字符过滤，否则有可能造成xss攻击
+	callback := template.JSEscapeString(r.Callback)
+	_, err = fmt.Fprintf(w, "%s(%s)", callback, ret)
+	return err
+}
+
+type XmlRender struct {
+	Data interface{}
+	Type string // 默认 application/xml，也可以是 text/xml
+}
+
+func (r XmlRender) ContentType() string {
+	if r.Type != "" {
+		return r.Type + "; charset=utf-8"
+	}
+	return MIMEXML + "; charset=utf-8"
+}
+
+func (r XmlRender) Render(w io.Writer) error {
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+type YamlRender struct {
+	Data interface{}
+	Type string
+}
+
+func (r YamlRender) ContentType() string {
+	if r.Type != "" {
+		return r.Type + "; charset=utf-8"
+	}
+	return MIMEYAML + "; charset=utf-8"
+}
+
+func (r YamlRender) Render(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	if err := encoder.Encode(r.Data); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+type MsgPackRender struct {
+	Data interface{}
+	Type string
+}
+
+func (r MsgPackRender) ContentType() string {
+	if r.Type != "" {
+		return r.Type
+	}
+	return MIMEMsgPack
+}
+
+func (r MsgPackRender) Render(w io.Writer) error {
+	return msgpack.NewEncoder(w).Encode(r.Data)
+}
+
+// Data 必须实现 proto.Message
+type ProtoBufRender struct {
+	Data interface{}
+}
+
+func (r ProtoBufRender) ContentType() string {
+	return MIMEProtoBuf
+}
+
+func (r ProtoBufRender) Render(w io.Writer) error {
+	message, ok := r.Data.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf render: %T does not implement proto.Message", r.Data)
+	}
+	byt, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(byt)
+	return err
+}
+
+type TextRender struct {
+	Format string
+	Values []interface{}
+}
+
+func (r TextRender) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (r TextRender) Render(w io.Writer) error {
+	_, err := fmt.Fprintf(w, r.Format, r.Values...)
+	return err
+}
+
+type HtmlRender struct {
+	File string // 模版文件
+	Data interface{}
+}
+
+func (r HtmlRender) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+func (r HtmlRender) Render(w io.Writer) error {
+	// 读取模版文件，创建template实例
+	t, err := template.ParseFiles(r.File)
+	if err != nil {
+		return err
+	}
+	// 执行Execute方法将obj和模版进行结合
+	return t.Execute(w, r.Data)
+}
+
+// Data 可以是 [][]string，或者结构体（指针）切片，
+// 结构体切片的表头使用 csv tag，没有 tag 时使用字段名，csv:"-" 忽略该字段
+type CsvRender struct {
+	Data interface{}
+}
+
+func (r CsvRender) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (r CsvRender) Render(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if records, ok := r.Data.([][]string); ok {
+		if err := writer.WriteAll(records); err != nil {
+			return err
+		}
+		return writer.Error()
+	}
+	rv := reflect.ValueOf(r.Data)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("csv render: unsupported type %T", r.Data)
+	}
+	elemType := rv.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("csv render: unsupported element type %s", elemType)
+	}
+	fields := csvFields(elemType)
+	header := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(fields))
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		for elem.Kind() == reflect.Ptr && !elem.IsNil() {
+			elem = elem.Elem()
+		}
+		for j, field := range fields {
+			record[j] = ""
+			if elem.Kind() == reflect.Struct {
+				record[j] = csvCell(elem.FieldByIndex(field.index))
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type csvField struct {
+	name  string
+	index []int
+}
+
+func csvFields(t reflect.Type) []csvField {
+	var fields []csvField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(sf.Tag.Get("csv"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, csvField{name: name, index: sf.Index})
+	}
+	return fields
+}
+
+func csvCell(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch val := v.Interface().(type) {
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case fmt.Stringer:
+		return val.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// 先渲染到缓冲区，渲染失败时还能返回 500
+func renderToBuffer(r Renderer) (*bytes.Buffer, error) {
+	if r == nil {
+		return nil, errors.New("render: nil renderer")
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
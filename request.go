@@ -9,11 +9,12 @@ import (
 	"github.com/textthree/cvgokit/castkit"
 	"github.com/textthree/provider"
 	"io"
-	"io/ioutil"
 	"mime/multipart"
+	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
 // 为请求封装方法，在 Context 上实现接口
@@ -22,6 +23,11 @@ type IRequest interface {
 	JsonScan(s any) error          // json body
 	BindXml(obj interface{}) error // xml body
 	GetRawData() ([]byte, error)   // 其他格式
+	Bind(obj any) error            // 按 tag 从 path、query、form、header、cookie、body 绑定到结构体
+	ContentType() string
+
+	// 路径参数，如路由 /user/:id 中的 id
+	Param(key string) (string, bool)
 
 	// 获取查询字符串中的参数，如: xxx.com?a=foo&b=bar&c[]=barbar
 	Get(key string) interface{}
@@ -42,6 +48,9 @@ type IRequest interface {
 	PostFloat64(key string, defaultValue ...float64) (float64, bool)
 	PostString(key string, defaultValue ...string) (string, bool)
 	PostBool(key string, defaultValue ...bool) (bool, bool)
+	PostSlice(key string, defaultValue ...[]interface{}) ([]interface{}, bool)
+	PostMap(key string, defaultValue ...map[string]interface{}) (map[string]interface{}, bool)
+	PostRaw(key string) (json.RawMessage, bool)
 
 	// form 表单中的参数
 	Form(key string) interface{}
@@ -53,12 +62,16 @@ type IRequest interface {
 	FormString(key string, defaultValue ...string) (string, bool)
 	FormStringSlice(key string, defaultValue ...[]string) ([]string, bool)
 	FormFile(key string, args ...int) (multipart.File, *multipart.FileHeader, url.Values, error)
+	FormFiles(key string, limits ...UploadLimits) ([]*multipart.FileHeader, error)
+	StreamMultipart(limits UploadLimits, handler func(part *UploadPart) error) error
 
 	// 其他格式
 	Uri() string
 	Method() string
 	Host() string
 	ClientIp() string
+	Scheme() string
+	BaseURL() string
 
 	// header
 	Headers() map[string][]string
@@ -271,23 +284,21 @@ func (req *ReqStruct) FormStringSlice(key string, defaultValue ...[]string) ([]s
 }
 
 // limit[0] 单位：byte
+// 返回的文件由调用方负责 Close
 func (req *ReqStruct) FormFile(key string, limit ...int) (multipart.File, *multipart.FileHeader, url.Values, error) {
-	limitMultipartMemory := 32 << 20 // 32 MB
+	limitMultipartMemory := int64(defaultMultipartMemory)
 	if len(limit) > 0 {
-		limitMultipartMemory = limit[0]
+		limitMultipartMemory = int64(limit[0])
 	}
-	if req.request.MultipartForm == nil {
-		if err := req.request.ParseMultipartForm(int64(limitMultipartMemory)); err != nil {
-			params := req.request.PostForm
-			return nil, nil, params, err
-		}
+	if err := req.parseMultipart(limitMultipartMemory); err != nil {
+		params := req.request.PostForm
+		return nil, nil, params, err
 	}
 	params := req.request.PostForm
 	f, handler, err := req.request.FormFile(key)
 	if err != nil {
 		return nil, nil, params, err
 	}
-	f.Close()
 	return f, handler, params, err
 }
 
@@ -327,13 +338,11 @@ func (req *ReqStruct) JsonScan(s any) error {
 	}
 	// POST、DELETE
 	if req.request != nil {
-		// 读取文本
-		body, err := io.ReadAll(req.request.Body)
+		// 读取文本，读取后会重新填充 request.Body，为后续的逻辑二次读取做准备
+		body, err := req.readBody()
 		if err != nil {
 			return err
 		}
-		// 重新填充request.Body，为后续的逻辑二次读取做准备
-		req.request.Body = io.NopCloser(bytes.NewBuffer(body))
 		// 解析到obj结构体中
 		err = json.Unmarshal(body, s)
 		if err != nil {
@@ -346,24 +355,19 @@ func (req *ReqStruct) JsonScan(s any) error {
 	return nil
 }
 func setFieldValue(field *reflect.Value, paramValue string) {
-	switch field.Kind() {
-	case reflect.String:
-		field.SetString(paramValue)
-	case reflect.Int:
-		if intValue, err := strconv.Atoi(paramValue); err == nil {
-			field.SetInt(int64(intValue))
-		}
+	if !field.CanSet() {
+		return
 	}
+	setWithString(*field, paramValue, "")
 }
 
 // xml body
 func (req *ReqStruct) BindXml(obj interface{}) error {
 	if req.request != nil {
-		body, err := ioutil.ReadAll(req.request.Body)
+		body, err := req.readBody()
 		if err != nil {
 			return err
 		}
-		req.request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
 		err = xml.Unmarshal([]byte(body), obj)
 		if err != nil {
 			return err
@@ -377,16 +381,17 @@ func (req *ReqStruct) BindXml(obj interface{}) error {
 // 其他格式
 func (req *ReqStruct) GetRawData() ([]byte, error) {
 	if req.request != nil {
-		body, err := ioutil.ReadAll(req.request.Body)
-		if err != nil {
-			return nil, err
-		}
-		req.request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
-		return body, nil
+		return req.readBody()
 	}
 	return nil, errors.New("req.request empty")
 }
 
+// 路径参数
+func (req *ReqStruct) Param(key string) (string, bool) {
+	val, ok := req.params[strings.ToLower(key)]
+	return val, ok
+}
+
 // 基础信息
 func (req *ReqStruct) Uri() string {
 	return req.request.RequestURI
@@ -396,22 +401,6 @@ func (req *ReqStruct) Method() string {
 	return req.request.Method
 }
 
-func (req *ReqStruct) Host() string {
-	return req.request.URL.Host
-}
-
-func (req *ReqStruct) ClientIp() string {
-	r := req.request
-	ipAddress := r.Header.Get("X-Real-Ip")
-	if ipAddress == "" {
-		ipAddress = r.Header.Get("X-Forwarded-For")
-	}
-	if ipAddress == "" {
-		ipAddress = r.RemoteAddr
-	}
-	return ipAddress
-}
-
 // header
 func (req *ReqStruct) Headers() map[string][]string {
 	return req.request.Header
@@ -444,20 +433,94 @@ func (req *ReqStruct) Cookie(key string) (string, bool) {
 }
 
 // /////////////////////////// POST START //////////////////////////////
-func (req *ReqStruct) post(key string) (castkit.GoodleVal, bool) {
-	ret := castkit.GoodleVal{}
-	if req.request != nil {
-		body, err := io.ReadAll(req.request.Body)
+// 默认 body 最大 10 MB，可通过 engine.SetMaxPostSize 修改
+const defaultMaxPostSize int64 = 10 << 20
+
+// 读取整个 body 后重新填充，最多读取 maxPostSize，超出时返回 ErrBodyTooLarge
+func (req *ReqStruct) readBody() ([]byte, error) {
+	if req.request.Body == nil || req.request.Body == http.NoBody {
+		return nil, nil
+	}
+	maxSize := req.maxPostSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxPostSize
+	}
+	body, err := io.ReadAll(io.LimitReader(req.request.Body, maxSize+1))
+	if err != nil || int64(len(body)) > maxSize {
+		// 没有读完时把剩余部分接在后面，其他读取 body 的方法看到的仍然是完整的 body
+		req.refillBody(io.MultiReader(bytes.NewReader(body), req.request.Body))
 		if err != nil {
-			return ret, false
+			return nil, err
 		}
-		params := map[string]interface{}{}
-		json.Unmarshal(body, &params)
-		if params[key] != nil {
-			return castkit.GoodleVal{params[key]}, true
+		return nil, ErrBodyTooLarge
+	}
+	req.refillBody(bytes.NewReader(body))
+	return body, nil
+}
+
+func (req *ReqStruct) refillBody(body io.Reader) {
+	if req.resetBody != nil {
+		req.resetBody(body)
+		return
+	}
+	req.request.Body = io.NopCloser(body)
+}
+
+// 每个请求只读取、解析一次 json body，解析结果缓存在 ReqStruct 上，
+// 使用 json.Number 保留数字原文，避免大的 int64 ID 转成 float64 后丢失精度
+func (req *ReqStruct) postParams() map[string]interface{} {
+	if req.postParsed {
+		return req.postData
+	}
+	req.postParsed = true
+	req.postData = map[string]interface{}{}
+	if req.request == nil {
+		return req.postData
+	}
+	body, err := req.readBody()
+	if err != nil {
+		req.postErr = err
+		return req.postData
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	decoder.Decode(&req.postData)
+	return req.postData
+}
+
+// key 支持用点号访问嵌套字段，如 "user.address.city"、"items.0.id"
+func (req *ReqStruct) postValue(key string) (interface{}, bool) {
+	params := req.postParams()
+	if val, ok := params[key]; ok {
+		return val, val != nil
+	}
+	var current interface{} = params
+	for _, part := range strings.Split(key, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			val, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = val
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
+			return nil, false
 		}
 	}
-	return ret, false
+	return current, current != nil
+}
+
+func (req *ReqStruct) post(key string) (castkit.GoodleVal, bool) {
+	if val, ok := req.postValue(key); ok {
+		return castkit.GoodleVal{val}, true
+	}
+	return castkit.GoodleVal{}, false
 }
 
 func (req *ReqStruct) PostInt(key string, defaultValue ...int) (int, bool) {
@@ -537,4 +600,43 @@ func (req *ReqStruct) PostBool(key string, defaultValue ...bool) (bool, bool) {
 	return false, false
 }
 
+// json 数组
+func (req *ReqStruct) PostSlice(key string, defaultValue ...[]interface{}) ([]interface{}, bool) {
+	if val, ok := req.postValue(key); ok {
+		if slice, ok := val.([]interface{}); ok {
+			return slice, true
+		}
+	}
+	if len(defaultValue) > 0 {
+		return defaultValue[0], false
+	}
+	return []interface{}{}, false
+}
+
+// json 对象
+func (req *ReqStruct) PostMap(key string, defaultValue ...map[string]interface{}) (map[string]interface{}, bool) {
+	if val, ok := req.postValue(key); ok {
+		if m, ok := val.(map[string]interface{}); ok {
+			return m, true
+		}
+	}
+	if len(defaultValue) > 0 {
+		return defaultValue[0], false
+	}
+	return map[string]interface{}{}, false
+}
+
+// 字段的 json 原文，可以再 json.Unmarshal 到具体结构体
+func (req *ReqStruct) PostRaw(key string) (json.RawMessage, bool) {
+	val, ok := req.postValue(key)
+	if !ok {
+		return nil, false
+	}
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return nil, false
+	}
+	return raw, true
+}
+
 ///////////////////////////// POST END //////////////////////////////
@@ -1,12 +1,11 @@
 package httpserver
 
 import (
-	"encoding/json"
-	"encoding/xml"
-	"fmt"
-	"html/template"
+	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // 为响应封装方法
@@ -16,102 +15,112 @@ type IResponse interface {
 	Html(template string, obj interface{}) IResponse
 	Jsonp(obj interface{}) IResponse
 	Xml(obj interface{}) IResponse
+	Yaml(obj interface{}) IResponse
+	MsgPack(obj interface{}) IResponse
+	ProtoBuf(obj interface{}) IResponse
+	Csv(obj interface{}) IResponse
 	Text(format string, values ...interface{}) IResponse
+	Render(r Renderer) IResponse    // 自定义格式
 	Redirect(path string) IResponse // 重定向
 	SetHeader(key string, val string) IResponse
 	SetCookie(key string, val string, maxAge int, path, domain string, secure, httpOnly bool) IResponse
+	// 原样写出 cookie，可以设置 SameSite、Expires 等
+	SetHttpCookie(cookie *http.Cookie) IResponse
 	SetOkStatus() IResponse       // 设置 200 状态
 	SetStatus(code int) IResponse // 设置其他状态码
+	Status() int
+	Size() int64 // 已写出的 body 字节数
+
+	// 文件
+	File(filePath string) IResponse
+	Attachment(filePath string, filename string) IResponse
+	Inline(filePath string, filename string) IResponse
+	FileFS(fsys fs.FS, name string) IResponse
+	ServeContent(name string, modtime time.Time, content io.ReadSeeker) IResponse
+	Stream(reader io.Reader, contentType string) IResponse
 }
 
-func (res *RespStruct) Json(obj interface{}) IResponse {
-	byt, err := json.Marshal(obj)
+// 使用渲染器输出，Content-Type 由渲染器决定
+// 状态码在写入 body 前才真正写出，所以 SetStatus(...).Json(...) 这样的链式调用 header 也能生效
+func (res *RespStruct) Render(r Renderer) IResponse {
+	buf, err := renderToBuffer(r)
+	if !res.lock() {
+		return res
+	}
+	defer res.unlock()
 	if err != nil {
-		return res.SetStatus(http.StatusInternalServerError)
+		res.status = http.StatusInternalServerError
+		res.writeStatus()
+		return res
 	}
-	res.SetHeader("Content-Type", "application/json")
-	res.responseWriter.Write(byt)
+	res.responseWriter.Header().Set("Content-Type", r.ContentType())
+	res.write(buf.Bytes())
 	return res
 }
 
+func (res *RespStruct) Json(obj interface{}) IResponse {
+	return res.Render(JsonRender{Data: obj})
+}
+
 // Jsonp输出
 func (res *RespStruct) Jsonp(obj interface{}) IResponse {
 	// 获取请求参数callback
 	callbackFunc := res.request.GetString("callback", "callback_function")
-	res.SetHeader("Content-Type", "application/javascript")
-	// 输出到前端页面的时候需要注意下进行字符过滤，否则有可能造成xss攻击
-	callback := template.JSEscapeString(callbackFunc)
-
-	// 输出函数名
-	_, err := res.responseWriter.Write([]byte(callback))
-	if err != nil {
-		return res
-	}
-	// 输出左括号
-	_, err = res.responseWriter.Write([]byte("("))
-	if err != nil {
-		return res
-	}
-	// 数据函数参数
-	ret, err := json.Marshal(obj)
-	if err != nil {
-		return res
-	}
-	_, err = res.responseWriter.Write(ret)
-	if err != nil {
-		return res
-	}
-	// 输出右括号
-	_, err = res.responseWriter.Write([]byte(")"))
-	if err != nil {
-		return res
-	}
-	return res
+	return res.Render(JsonpRender{Callback: callbackFunc, Data: obj})
 }
 
 // xml输出
 func (res *RespStruct) Xml(obj interface{}) IResponse {
-	byt, err := xml.Marshal(obj)
-	if err != nil {
-		return res.SetStatus(http.StatusInternalServerError)
-	}
-	res.SetHeader("Content-Type", "application/html")
-	res.responseWriter.Write(byt)
-	return res
+	return res.Render(XmlRender{Data: obj})
+}
+
+// yaml输出
+func (res *RespStruct) Yaml(obj interface{}) IResponse {
+	return res.Render(YamlRender{Data: obj})
+}
+
+// msgpack输出
+func (res *RespStruct) MsgPack(obj interface{}) IResponse {
+	return res.Render(MsgPackRender{Data: obj})
+}
+
+// protobuf输出，obj 必须实现 proto.Message
+func (res *RespStruct) ProtoBuf(obj interface{}) IResponse {
+	return res.Render(ProtoBufRender{Data: obj})
+}
+
+// csv输出，obj 为 [][]string 或结构体切片
+func (res *RespStruct) Csv(obj interface{}) IResponse {
+	return res.Render(CsvRender{Data: obj})
 }
 
 // html输出
 func (res *RespStruct) Html(file string, obj interface{}) IResponse {
-	// 读取模版文件，创建template实例
-	t, err := template.New("output").ParseFiles(file)
-	if err != nil {
-		return res
-	}
-	// 执行Execute方法将obj和模版进行结合
-	if err := t.Execute(res.responseWriter, obj); err != nil {
-		return res
-	}
-
-	res.SetHeader("Content-Type", "text/html")
-	return res
+	return res.Render(HtmlRender{File: file, Data: obj})
 }
 
 // string
 func (res *RespStruct) Text(format string, values ...interface{}) IResponse {
-	out := fmt.Sprintf(format, values...)
-	res.SetHeader("Content-Type", "text/plain; charset=utf-8")
-	res.responseWriter.Write([]byte(out))
-	return res
+	return res.Render(TextRender{Format: format, Values: values})
 }
 
 // 重定向
 func (res *RespStruct) Redirect(path string) IResponse {
+	if !res.lock() {
+		return res
+	}
+	defer res.unlock()
 	http.Redirect(res.responseWriter, res.request.request, path, http.StatusMovedPermanently)
+	res.wroteHeader = true
 	return res
 }
 
 // header
 func (res *RespStruct) SetHeader(key string, val string) IResponse {
+	if !res.lock() {
+		return res
+	}
+	defer res.unlock()
 	res.responseWriter.Header().Add(key, val)
 	return res
 }
@@ -121,27 +130,116 @@ func (res *RespStruct) SetCookie(key string, val string, maxAge int, path string
 	if path == "" {
 		path = "/"
 	}
-	http.SetCookie(res.responseWriter, &http.Cookie{
+	return res.SetHttpCookie(&http.Cookie{
 		Name:     key,
 		Value:    url.QueryEscape(val),
 		MaxAge:   maxAge,
 		Path:     path,
 		Domain:   domain,
-		SameSite: 1,
+		SameSite: http.SameSiteDefaultMode,
 		Secure:   secure,
 		HttpOnly: httpOnly,
 	})
+}
+
+func (res *RespStruct) SetHttpCookie(cookie *http.Cookie) IResponse {
+	if !res.lock() {
+		return res
+	}
+	defer res.unlock()
+	http.SetCookie(res.responseWriter, cookie)
 	return res
 }
 
 // 设置状态码
 func (res *RespStruct) SetStatus(code int) IResponse {
-	res.responseWriter.WriteHeader(code)
+	if !res.lock() {
+		return res
+	}
+	defer res.unlock()
+	res.status = code
 	return res
 }
 
 // 设置200状态
 func (res *RespStruct) SetOkStatus() IResponse {
-	res.responseWriter.WriteHeader(http.StatusOK)
-	return res
+	return res.SetStatus(http.StatusOK)
+}
+
+// 状态码，已经写出时为实际写出的状态码，否则为 SetStatus 设置的状态码，都没有时为 200
+func (res *RespStruct) Status() int {
+	res.writerMux.Lock()
+	defer res.writerMux.Unlock()
+	if res.recorder != nil && res.recorder.status != 0 {
+		return res.recorder.status
+	}
+	if res.status == 0 {
+		return http.StatusOK
+	}
+	return res.status
+}
+
+// 已写出的 body 字节数
+func (res *RespStruct) Size() int64 {
+	res.writerMux.Lock()
+	defer res.writerMux.Unlock()
+	if res.recorder == nil {
+		return 0
+	}
+	return res.recorder.size
+}
+
+// 写出状态码，只生效一次
+func (res *RespStruct) writeHeader() {
+	if !res.lock() {
+		return
+	}
+	defer res.unlock()
+	res.writeStatus()
+}
+
+// 实现 io.Writer，第一次写入前写出状态码，超时后返回 http.ErrHandlerTimeout
+func (res *RespStruct) Write(data []byte) (int, error) {
+	if !res.lock() {
+		return 0, http.ErrHandlerTimeout
+	}
+	defer res.unlock()
+	return res.write(data)
+}
+
+// 加写锁，已经超时时不加锁并返回 false，调用方不能再写响应。
+// 持有锁期间只能调用 writeStatus、write 这类不加锁的方法
+func (res *RespStruct) lock() bool {
+	res.writerMux.Lock()
+	if res.hasTimeout.Load() {
+		res.writerMux.Unlock()
+		return false
+	}
+	return true
+}
+
+func (res *RespStruct) unlock() {
+	res.writerMux.Unlock()
+}
+
+// 是否已经开始写出响应，超时也算作已经写出
+func (res *RespStruct) started() bool {
+	res.writerMux.Lock()
+	defer res.writerMux.Unlock()
+	return res.hasTimeout.Load() || res.recorder.status != 0
+}
+
+func (res *RespStruct) writeStatus() {
+	if res.wroteHeader {
+		return
+	}
+	res.wroteHeader = true
+	if res.status != 0 {
+		res.responseWriter.WriteHeader(res.status)
+	}
+}
+
+func (res *RespStruct) write(data []byte) (int, error) {
+	res.writeStatus()
+	return res.responseWriter.Write(data)
 }
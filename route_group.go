@@ -7,6 +7,7 @@ type IGroup interface {
 	Post(string, RequestHandler, ...MiddlewareHandler)
 	Put(string, RequestHandler, ...MiddlewareHandler)
 	Delete(string, RequestHandler, ...MiddlewareHandler)
+	WS(string, WebSocketHandler, ...MiddlewareHandler)
 	UseMiddleware(...MiddlewareHandler) IGroup
 }
 
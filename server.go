@@ -0,0 +1,153 @@
+// http.Server 超时与连接数限制
+package httpserver
+
+import (
+	"github.com/spf13/cast"
+	"github.com/textthree/provider/config"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// http.Server 配置，Run 启动服务时使用
+type ServerConfig struct {
+	// 监听地址，为空时监听配置文件中的端口，支持 tcp、unix socket 和 systemd 传递的 socket，写法见 cvgohttp.WithListen
+	Listen []string
+	// 读取整个请求（含 body）的超时，0 表示不限制，上传大文件时注意调大
+	ReadTimeout time.Duration
+	// 读取请求头的超时，防止 slowloris 攻击，0 表示默认 10s
+	ReadHeaderTimeout time.Duration
+	// 写响应的超时，0 表示不限制。SSE、WebSocket 等长连接不要设置
+	WriteTimeout time.Duration
+	// keep-alive 空闲连接超时，0 表示默认 120s
+	IdleTimeout time.Duration
+	// 请求头最大字节数，0 表示默认 1MB
+	MaxHeaderBytes int
+	// 最大并发连接数，超出时直接关闭新连接，0 表示不限制
+	MaxConnections int
+}
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+)
+
+// 修改 http.Server 配置
+func (self *Engine) SetServerConfig(cfg ServerConfig) {
+	self.serverConfig = cfg
+}
+
+// http.Server 配置，未设置的超时使用默认值
+func (self *Engine) ServerConfig() ServerConfig {
+	cfg := self.serverConfig
+	if cfg.ReadHeaderTimeout == 0 {
+		cfg.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+	return cfg
+}
+
+// 从 http.listen、http.readTimeout、http.readHeaderTimeout、http.writeTimeout、http.idleTimeout、
+// http.maxHeaderBytes、http.maxConnections 读取配置
+func loadServerConfig(cfg config.Service) ServerConfig {
+	serverCfg := ServerConfig{
+		ReadTimeout:       configDuration(cfg, "http.readTimeout"),
+		ReadHeaderTimeout: configDuration(cfg, "http.readHeaderTimeout"),
+		WriteTimeout:      configDuration(cfg, "http.writeTimeout"),
+		IdleTimeout:       configDuration(cfg, "http.idleTimeout"),
+		MaxHeaderBytes:    int(configByteSize(cfg, "http.maxHeaderBytes")),
+	}
+	if val, ok := configValue(cfg, "http.listen"); ok {
+		serverCfg.Listen = cast.ToStringSlice(val)
+	}
+	if val, ok := configValue(cfg, "http.maxConnections"); ok {
+		serverCfg.MaxConnections = cast.ToInt(val)
+	}
+	return serverCfg
+}
+
+// 连接统计
+type ConnStats struct {
+	Active   int64  // 当前连接数
+	Accepted uint64 // 累计接受的连接数
+	Rejected uint64 // 因超出 MaxConnections 被拒绝的连接数
+}
+
+type connCounters struct {
+	active   atomic.Int64
+	accepted atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// 连接统计，用于监控
+func (self *Engine) ConnStats() ConnStats {
+	return ConnStats{
+		Active:   self.connCounters.active.Load(),
+		Accepted: self.connCounters.accepted.Load(),
+		Rejected: self.connCounters.rejected.Load(),
+	}
+}
+
+// 包装 listener，统计连接数，超出 MaxConnections 时关闭新连接
+// 与阻塞等待的做法不同，直接拒绝可以让负载均衡尽快把请求转到其他实例
+func (self *Engine) LimitListener(l net.Listener) net.Listener {
+	return &limitListener{
+		Listener: l,
+		max:      int64(self.serverConfig.MaxConnections),
+		counters: self.connCounters,
+	}
+}
+
+type limitListener struct {
+	net.Listener
+	max      int64
+	counters *connCounters
+}
+
+func (self *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := self.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if !self.acquire() {
+			self.counters.rejected.Add(1)
+			conn.Close()
+			continue
+		}
+		self.counters.accepted.Add(1)
+		return &limitConn{Conn: conn, counters: self.counters}, nil
+	}
+}
+
+// 占用一个连接名额，多个 listener 共享计数器，检查和递增必须是一次原子操作
+func (self *limitListener) acquire() bool {
+	if self.max <= 0 {
+		self.counters.active.Add(1)
+		return true
+	}
+	for {
+		active := self.counters.active.Load()
+		if active >= self.max {
+			return false
+		}
+		if self.counters.active.CompareAndSwap(active, active+1) {
+			return true
+		}
+	}
+}
+
+type limitConn struct {
+	net.Conn
+	counters *connCounters
+	once     sync.Once
+}
+
+func (self *limitConn) Close() error {
+	err := self.Conn.Close()
+	self.once.Do(func() { self.counters.active.Add(-1) })
+	return err
+}
@@ -4,15 +4,18 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"github.com/spf13/cast"
 	"github.com/textthree/provider/config"
 	"github.com/textthree/provider/core"
 	"github.com/textthree/provider/core/types"
 	"html/template"
 	"io/fs"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 const (
@@ -26,6 +29,7 @@ type RequestHandler func(c *Context) // API / 控制器函数
 // 框架核心结构体
 type Engine struct {
 	router              map[string]map[string]t3WebRoute
+	paramRouter         map[string][]t3ParamRoute // 带路径参数的路由，如 /user/:id，按注册顺序逐段匹配
 	globalMiddlewares   []MiddlewareHandler
 	groupMiddlewares    map[string][]MiddlewareHandler
 	requestHandler      RequestHandler
@@ -33,6 +37,24 @@ type Engine struct {
 	cross               bool
 	swaggerUiFileSystem fs.FS
 	config              config.Service
+	errorHandler        ErrorHandler
+	maxPostSize         int64 // Post* 方法解析 json body 的最大字节数
+	bodyLimit           int64 // 全局请求 body 大小限制，0 表示不限制
+	trustedProxies      []*net.IPNet
+	websocketConfig     WebSocketConfig
+	startHooks          []Hook
+	readyHooks          []Hook
+	shutdownHooks       []Hook
+	startTimeout        time.Duration
+	shutdownTimeout     time.Duration
+	shutdownHookTimeout time.Duration
+	tlsConfig           TLSConfig
+	http2Config         HTTP2Config
+	serverConfig        ServerConfig
+	connCounters        *connCounters
+	health              engineHealth
+	sessionConfig       *SessionConfig
+	configErr           error // 创建时读取配置出错，由 Run 返回
 }
 
 type t3WebRoute struct {
@@ -40,6 +62,12 @@ type t3WebRoute struct {
 	requestHandler RequestHandler
 	routeType      int8 // 路由类型：1.golang 静态路由 2.golang 分组路由
 	prefix         string
+	pattern        string // 注册时的完整路径，如 /user/:id
+}
+
+type t3ParamRoute struct {
+	segments []string
+	route    t3WebRoute
 }
 
 // 使用 embed 包嵌入 swagger-ui 目录下的所有文件。
@@ -58,12 +86,35 @@ func (self *Engine) NewHttpEngine(serviceCenter core.Container, cfgsvc config.Se
 	router["POST"] = map[string]t3WebRoute{}
 	router["PUT"] = map[string]t3WebRoute{}
 	router["DELETE"] = map[string]t3WebRoute{}
+	checkConfigGetter(cfgsvc)
 	engine = &Engine{
-		router:           router,
-		groupMiddlewares: map[string][]MiddlewareHandler{}, // 分组路由(批量前缀)路由上挂的中间件
-		container:        serviceCenter,
-		config:           cfgsvc,
+		router:              router,
+		paramRouter:         map[string][]t3ParamRoute{},
+		groupMiddlewares:    map[string][]MiddlewareHandler{}, // 分组路由(批量前缀)路由上挂的中间件
+		container:           serviceCenter,
+		config:              cfgsvc,
+		errorHandler:        DefaultErrorHandler,
+		bodyLimit:           configByteSize(cfgsvc, "http.bodyLimit"),
+		startTimeout:        configDuration(cfgsvc, "http.startTimeout"),
+		shutdownTimeout:     configDuration(cfgsvc, "http.shutdownTimeout"),
+		shutdownHookTimeout: configDuration(cfgsvc, "http.shutdownHookTimeout"),
+		http2Config:         loadHTTP2Config(cfgsvc),
+		serverConfig:        loadServerConfig(cfgsvc),
+		connCounters:        &connCounters{},
+		health:              newHealth(cfgsvc),
+	}
+	// 可信代理
+	if val, ok := configValue(cfgsvc, "http.trustedProxies"); ok {
+		if err := engine.SetTrustedProxies(cast.ToStringSlice(val)...); err != nil {
+			engine.configErr = fmt.Errorf("http.trustedProxies: %w", err)
+		}
 	}
+	// TLS
+	tlsConfig, err := loadTLSConfig(cfgsvc)
+	if err != nil {
+		engine.configErr = errors.Join(engine.configErr, fmt.Errorf("http.tls: %w", err))
+	}
+	engine.tlsConfig = tlsConfig
 	// swagger 支持
 	if cfg := cfgsvc.GetSwagger(); cfg.FilePath != "" {
 		// 创建子文件系统以指向 swagger-ui 目录
@@ -77,11 +128,26 @@ func (self *Engine) NewHttpEngine(serviceCenter core.Container, cfgsvc config.Se
 	return
 }
 
+// 创建时读取配置出错（如可信代理格式错误、证书文件不存在）返回错误，Run 不会启动服务
+func (self *Engine) ConfigError() error {
+	return self.configErr
+}
+
 // 注册全局中间件
 func (self *Engine) UseMiddleware(handlers ...MiddlewareHandler) {
 	self.globalMiddlewares = handlers
 }
 
+// Post*、JsonScan、BindXml、GetRawData、Bind 读取 body 的最大字节数，默认 10 MB，超出时返回 413
+func (self *Engine) SetMaxPostSize(size int64) {
+	self.maxPostSize = size
+}
+
+// 全局请求 body 大小限制，单个路由或分组可以使用 middleware.BodyLimit 覆盖
+func (self *Engine) SetBodyLimit(size int64) {
+	self.bodyLimit = size
+}
+
 // 跨域
 func (self *Engine) Cross() {
 	self.cross = true
@@ -99,13 +165,26 @@ func (self *Engine) AddRoute(method, prefix, uri string, routeType int8, handler
 		err := errors.New("route exist: " + uri)
 		panic(err)
 	}
-	key := strings.Replace(prefix+uri, "/", "", -1)
-	self.router[method][key] = t3WebRoute{
+	route := t3WebRoute{
 		middlewares:    middlewares,
 		requestHandler: handler,
 		routeType:      routeType,
 		prefix:         prefix,
+		pattern:        "/" + strings.Join(splitPath(strings.ToLower(prefix)+uri), "/"),
 	}
+	if strings.Contains(uri, "/:") {
+		self.paramRouter[method] = append(self.paramRouter[method], t3ParamRoute{
+			segments: splitPath(strings.ToLower(prefix) + uri),
+			route:    route,
+		})
+		return
+	}
+	key := strings.Replace(prefix+uri, "/", "", -1)
+	self.router[method][key] = route
+}
+
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
 }
 
 func Cross(response http.ResponseWriter) {
@@ -122,6 +201,10 @@ func (self *Engine) ServeHTTP(response http.ResponseWriter, request *http.Reques
 	if request.URL.Path == "/favicon.ico" {
 		return
 	}
+	// 健康检查不经过路由和中间件
+	if self.health.serve(response, request) {
+		return
+	}
 	if request.Method == "OPTIONS" {
 		response.WriteHeader(200)
 		return
@@ -140,34 +223,56 @@ func (self *Engine) ServeHTTP(response http.ResponseWriter, request *http.Reques
 
 	// 初始化自定义 context
 	ctx := NewContext(request, response, self.container)
+	// 保存 session、写出只设置了的状态码、清理上传的临时文件
+	defer ctx.finish()
+	ctx.errorHandler = self.errorHandler
+	ctx.setMaxPostSize(self.maxPostSize)
+	ctx.setTrustedProxies(self.trustedProxies)
+	ctx.sessionConfig = self.sessionConfig
+	// 全局限制先只包装 body，Content-Length 检查放到控制器之前，让路由上的 BodyLimit 有机会放宽限制
+	ctx.limitBody(self.bodyLimit)
 
 	// 寻找路由，handlers 包含中间件 + 控制器
-	route := self.FindRouteHandler(request)
+	route, params := self.findRoute(request)
 	if route.IsEmpty() {
 		ctx.Resp.SetStatus(404).Text("404 not found")
 		return
 	}
+	ctx.setParams(params)
+	ctx.routePattern = route.pattern
 	// 注入中间件、控制器给 context
-	middlewareChain := self.globalMiddlewares
+	// 控制器作为调用链的最后一环，这样 Timeout、Recovery 等中间件才能包住控制器的执行
+	groupMiddlewares := self.groupMiddlewares[route.prefix]
+	middlewareChain := make([]MiddlewareHandler, 0, len(self.globalMiddlewares)+len(groupMiddlewares)+len(route.middlewares)+1)
+	middlewareChain = append(middlewareChain, self.globalMiddlewares...)
 	if route.prefix != "" {
-		middlewareChain = append(middlewareChain, self.groupMiddlewares[route.prefix]...)
-	}
-	for _, middleware := range route.middlewares {
-		middlewareChain = append(middlewareChain, middleware)
+		middlewareChain = append(middlewareChain, groupMiddlewares...)
 	}
+	middlewareChain = append(middlewareChain, route.middlewares...)
+	middlewareChain = append(middlewareChain, func(c *Context) error {
+		if err := c.checkContentLength(); err != nil {
+			return err
+		}
+		// 执行控制器函数
+		route.requestHandler(c)
+		return c.postBodyError()
+	})
 	ctx.SetMiddwares(middlewareChain)
 	// 执行中间件、控制器
 	if err := ctx.Next(); err != nil {
-		ctx.Resp.SetStatus(500).Text(err.Error())
+		ctx.Error(err)
 		return
 	}
-
-	// 执行控制器函数
-	route.requestHandler(ctx)
 }
 
 // 匹配路由，如果没有匹配到，返回 nil
 func (self *Engine) FindRouteHandler(request *http.Request) t3WebRoute {
+	route, _ := self.findRoute(request)
+	return route
+}
+
+// 先精确匹配静态路由，再逐段匹配带路径参数的路由
+func (self *Engine) findRoute(request *http.Request) (t3WebRoute, map[string]string) {
 	// 转换大小写，确保大小写不敏感
 	method := strings.ToUpper(request.Method)
 	key := strings.Replace(request.URL.Path, "/", "", -1)
@@ -181,10 +286,33 @@ func (self *Engine) FindRouteHandler(request *http.Request) t3WebRoute {
 	// 查找第一层 map
 	if methodHandlers, ok := self.router[method]; ok {
 		if handler, ok := methodHandlers[key]; ok {
-			return handler
+			return handler, nil
+		}
+	}
+	segments := splitPath(request.URL.Path)
+	for _, paramRoute := range self.paramRouter[method] {
+		if params, ok := paramRoute.match(segments); ok {
+			return paramRoute.route, params
+		}
+	}
+	return t3WebRoute{}, nil
+}
+
+func (self t3ParamRoute) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(self.segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range self.segments {
+		if strings.HasPrefix(segment, ":") {
+			params[segment[1:]] = segments[i]
+			continue
+		}
+		if segment != strings.ToLower(segments[i]) {
+			return nil, false
 		}
 	}
-	return t3WebRoute{}
+	return params, true
 }
 
 func (self *Engine) handelSwaggerUI(request *http.Request, response http.ResponseWriter) {
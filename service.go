@@ -7,4 +7,22 @@ import (
 type HttpServerService struct {
 	container core.Container
 	*Engine
+	lifecycle []interface{} // engine 创建前注册的生命周期对象
+}
+
+// 注册生命周期对象，engine 还没有创建时先保存，SetEngine 时再注册到 engine
+// 其他服务可以在 AfterInit 中通过容器取到 httpserver 服务并注册自己
+func (self *HttpServerService) RegisterLifecycle(targets ...interface{}) {
+	if self.Engine == nil {
+		self.lifecycle = append(self.lifecycle, targets...)
+		return
+	}
+	self.Engine.RegisterLifecycle(targets...)
+}
+
+// 设置服务使用的 engine
+func (self *HttpServerService) SetEngine(engine *Engine) {
+	self.Engine = engine
+	engine.RegisterLifecycle(self.lifecycle...)
+	self.lifecycle = nil
 }
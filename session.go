@@ -0,0 +1,310 @@
+// 服务端 session，数据保存在签名 cookie、加密 cookie、内存或 Redis 中
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/textthree/cvgokit/castkit"
+	"github.com/textthree/provider"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// session 的数据，存储实现负责序列化
+type SessionData struct {
+	ID         string                 `json:"id"`
+	Values     map[string]interface{} `json:"values,omitempty"`
+	Flashes    map[string]interface{} `json:"flashes,omitempty"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	AccessedAt time.Time              `json:"accessedAt"`
+}
+
+// session 存储
+type SessionStore interface {
+	// 按 cookie 的值加载，不存在、过期或校验失败时返回 nil, nil
+	Load(ctx context.Context, cookieValue string) (*SessionData, error)
+	// 保存 data，ttl 后过期，返回写入 cookie 的值（服务端存储为 ID，cookie 存储为编码后的数据）
+	Save(ctx context.Context, data *SessionData, ttl time.Duration) (string, error)
+	// 删除 ID 对应的数据，cookie 存储无需处理
+	Delete(ctx context.Context, id string) error
+}
+
+type SessionConfig struct {
+	// 默认 NewMemorySessionStore()，多实例部署使用 Redis 或 cookie 存储
+	Store SessionStore
+	// cookie 名称，默认 session_id
+	CookieName string
+	// cookie 的 Path，默认 /
+	Path   string
+	Domain string
+	// 为 true 时只通过 https 发送，https 请求总是设置 Secure
+	Secure bool
+	// 默认 Lax
+	SameSite http.SameSite
+	// 空闲超时，超过这么久没有请求时失效，默认 30 分钟
+	IdleTimeout time.Duration
+	// 绝对超时，从创建起超过这么久失效，即使一直在使用，默认 24 小时
+	AbsoluteTimeout time.Duration
+	// 为 true 时 cookie 设置 Max-Age，关闭浏览器后仍然有效，默认是浏览器会话 cookie
+	Persistent bool
+}
+
+// 开启 session，之后在控制器、中间件中通过 ctx.Session() 使用
+//
+//	engine.EnableSessions(httpserver.SessionConfig{Store: httpserver.NewRedisSessionStore(redisClient), Secure: true})
+func (self *Engine) EnableSessions(cfg SessionConfig) {
+	if cfg.Store == nil {
+		cfg.Store = NewMemorySessionStore()
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "session_id"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 30 * time.Minute
+	}
+	if cfg.AbsoluteTimeout <= 0 {
+		cfg.AbsoluteTimeout = 24 * time.Hour
+	}
+	self.sessionConfig = &cfg
+}
+
+// 访问时间超过这么久才重新保存，避免只读的请求每次都写存储
+const sessionTouchInterval = time.Minute
+
+// 一次请求中的 session
+type Session struct {
+	mu        sync.Mutex
+	ctx       *Context
+	config    *SessionConfig
+	data      *SessionData
+	oldID     string // Regenerate 前的 ID，提交时删除
+	isNew     bool
+	modified  bool
+	destroy   bool
+	committed bool
+}
+
+// 当前请求的 session，第一次调用时从 cookie 加载，没有或已过期时创建新的 session。
+// 修改在第一次写出响应前保存并写入 cookie，写出响应之后的修改不会保存
+func (ctx *Context) Session() *Session {
+	if ctx.session != nil {
+		return ctx.session
+	}
+	if ctx.sessionConfig == nil {
+		panic("sessions are not enabled, call engine.EnableSessions first")
+	}
+	session := &Session{ctx: ctx, config: ctx.sessionConfig}
+	session.load()
+	ctx.session = session
+	ctx.Resp.recorder.beforeWrite = session.commit
+	return session
+}
+
+func (ctx *Context) commitSession() {
+	if ctx.session != nil {
+		ctx.session.commit()
+	}
+}
+
+func (self *Session) load() {
+	now := time.Now()
+	if cookie, err := self.ctx.request.Cookie(self.config.CookieName); err == nil && cookie.Value != "" {
+		data, err := self.config.Store.Load(self.ctx, cookie.Value)
+		if err != nil {
+			provider.Clog().Error("[Load session fail]", err)
+		}
+		if data != nil && !self.expired(data, now) {
+			self.data = data
+			return
+		}
+		// 过期的 session 从存储中删除；客户端带来的未知 ID 不会被采用，防止 session 固定攻击
+		if data != nil {
+			self.config.Store.Delete(self.ctx, data.ID)
+		}
+	}
+	self.data = &SessionData{ID: newSessionID(), CreatedAt: now, AccessedAt: now}
+	self.isNew = true
+}
+
+func (self *Session) expired(data *SessionData, now time.Time) bool {
+	return now.Sub(data.AccessedAt) > self.config.IdleTimeout || now.Sub(data.CreatedAt) > self.config.AbsoluteTimeout
+}
+
+func newSessionID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (self *Session) ID() string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.data.ID
+}
+
+// 本次请求新创建的 session
+func (self *Session) IsNew() bool {
+	return self.isNew
+}
+
+func (self *Session) Get(key string) *castkit.GoodleVal {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return &castkit.GoodleVal{self.data.Values[key]}
+}
+
+func (self *Session) Has(key string) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	_, ok := self.data.Values[key]
+	return ok
+}
+
+// 值需要能被存储序列化（cookie、Redis 存储使用 JSON），读取时数字为 float64，可以用 Get(key).ToInt() 转换
+func (self *Session) Set(key string, value interface{}) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.data.Values == nil {
+		self.data.Values = map[string]interface{}{}
+	}
+	self.data.Values[key] = value
+	self.modified = true
+}
+
+func (self *Session) Delete(key string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if _, ok := self.data.Values[key]; ok {
+		delete(self.data.Values, key)
+		self.modified = true
+	}
+}
+
+// 设置只读取一次的消息，如重定向后展示的提示
+func (self *Session) Flash(key string, value interface{}) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.data.Flashes == nil {
+		self.data.Flashes = map[string]interface{}{}
+	}
+	self.data.Flashes[key] = value
+	self.modified = true
+}
+
+// 读取并删除 Flash 设置的消息
+func (self *Session) GetFlash(key string) *castkit.GoodleVal {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	value, ok := self.data.Flashes[key]
+	if ok {
+		delete(self.data.Flashes, key)
+		self.modified = true
+	}
+	return &castkit.GoodleVal{value}
+}
+
+// 更换 session ID 并保留数据，登录、提升权限后必须调用，防止 session 固定攻击。
+// 创建时间保持不变，绝对超时仍然从最初创建 session 时计算
+func (self *Session) Regenerate() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if !self.isNew && self.oldID == "" {
+		self.oldID = self.data.ID
+	}
+	self.data.ID = newSessionID()
+	self.modified = true
+}
+
+// 清空数据并删除 cookie，用于退出登录
+func (self *Session) Destroy() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.destroy = true
+	self.data.Values = nil
+	self.data.Flashes = nil
+}
+
+// 保存 session 并写入 cookie，在第一次写出响应前或请求结束时调用
+func (self *Session) commit() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.committed {
+		return
+	}
+	self.committed = true
+	store := self.config.Store
+	// 响应写出后才第一次调用 ctx.Session() 时不会触发写出前的保存，header 已经发出，
+	// 新建或更换了 ID 的 session 无法把 cookie 送达客户端，放弃保存，原来的 session 保持不变
+	if self.responseStarted() && !self.destroy && self.modified && (self.isNew || self.oldID != "") {
+		provider.Clog().Error("[Save session fail]", "session changed after the response was written, the session cookie cannot be set")
+		return
+	}
+	if self.oldID != "" {
+		if err := store.Delete(self.ctx, self.oldID); err != nil {
+			provider.Clog().Error("[Delete session fail]", err)
+		}
+	}
+	if self.destroy {
+		if !self.isNew {
+			if err := store.Delete(self.ctx, self.data.ID); err != nil {
+				provider.Clog().Error("[Delete session fail]", err)
+			}
+			self.writeCookie("", -1)
+		}
+		return
+	}
+	now := time.Now()
+	touch := now.Sub(self.data.AccessedAt) >= sessionTouchInterval
+	// 新 session 没有写入数据时不保存，避免每个匿名请求都创建 session
+	if !self.modified && (self.isNew || !touch) {
+		return
+	}
+	self.data.AccessedAt = now
+	ttl := min(self.config.IdleTimeout, self.config.AbsoluteTimeout-now.Sub(self.data.CreatedAt))
+	value, err := store.Save(self.ctx, self.data, ttl)
+	if err != nil {
+		provider.Clog().Error("[Save session fail]", err)
+		return
+	}
+	maxAge := 0
+	if self.config.Persistent {
+		maxAge = int((self.config.AbsoluteTimeout - now.Sub(self.data.CreatedAt)).Seconds())
+	}
+	self.writeCookie(value, maxAge)
+}
+
+// commit 只在写出前的回调（已经持有写锁）或者业务 Goroutine 都结束后执行，不需要再加锁
+func (self *Session) responseStarted() bool {
+	return self.ctx.HasTimeout() || self.ctx.Resp.recorder.status != 0
+}
+
+func (self *Session) writeCookie(value string, maxAge int) {
+	if self.responseStarted() {
+		// 已经保存到存储中的服务端 session 仍然可以通过原来的 cookie 访问，cookie 存储的修改则会丢失
+		if cookie, err := self.ctx.request.Cookie(self.config.CookieName); err != nil || cookie.Value != value {
+			provider.Clog().Error("[Save session fail]", "the response was already written, the session cookie cannot be updated")
+		}
+		return
+	}
+	// 可能在写出响应前的回调中执行，此时已经持有写锁，直接写 header
+	http.SetCookie(self.ctx.Resp.responseWriter, &http.Cookie{
+		Name:     self.config.CookieName,
+		Value:    value,
+		Path:     self.config.Path,
+		Domain:   self.config.Domain,
+		MaxAge:   maxAge,
+		Secure:   self.config.Secure || self.ctx.Scheme() == "https",
+		HttpOnly: true,
+		SameSite: self.config.SameSite,
+	})
+}
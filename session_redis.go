@@ -0,0 +1,54 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// 基于 Redis 的 session 存储，多个实例共享 session，过期由 Redis 的 TTL 处理
+type RedisSessionStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// client 可以是 *redis.Client、*redis.ClusterClient 等，prefix 默认 "session:"
+func NewRedisSessionStore(client redis.Cmdable, prefix ...string) *RedisSessionStore {
+	store := &RedisSessionStore{client: client, prefix: "session:"}
+	if len(prefix) > 0 {
+		store.prefix = prefix[0]
+	}
+	return store
+}
+
+func (self *RedisSessionStore) Load(ctx context.Context, cookieValue string) (*SessionData, error) {
+	encoded, err := self.client.Get(ctx, self.prefix+cookieValue).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := &SessionData{}
+	if err = json.Unmarshal(encoded, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (self *RedisSessionStore) Save(ctx context.Context, data *SessionData, ttl time.Duration) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	if err = self.client.Set(ctx, self.prefix+data.ID, encoded, ttl).Err(); err != nil {
+		return "", err
+	}
+	return data.ID, nil
+}
+
+func (self *RedisSessionStore) Delete(ctx context.Context, id string) error {
+	return self.client.Del(ctx, self.prefix+id).Err()
+}
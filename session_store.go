@@ -0,0 +1,220 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 浏览器对单个 cookie 的大小限制
+const maxCookieSize = 4096
+
+var ErrSessionTooLarge = errors.New("session: encoded data exceeds cookie size limit")
+
+// 进程内的 session 存储，只适合单实例部署，重启后 session 丢失
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+type memorySession struct {
+	data     []byte
+	expireAt time.Time
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]memorySession{}}
+}
+
+func (self *MemorySessionStore) Load(ctx context.Context, cookieValue string) (*SessionData, error) {
+	self.mu.Lock()
+	item, ok := self.sessions[cookieValue]
+	self.mu.Unlock()
+	if !ok || time.Now().After(item.expireAt) {
+		return nil, nil
+	}
+	data := &SessionData{}
+	if err := json.Unmarshal(item.data, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// 保存序列化后的副本，与其他存储的行为一致
+func (self *MemorySessionStore) Save(ctx context.Context, data *SessionData, ttl time.Duration) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.sweep(now)
+	self.sessions[data.ID] = memorySession{data: encoded, expireAt: now.Add(ttl)}
+	return data.ID, nil
+}
+
+func (self *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.sessions, id)
+	return nil
+}
+
+// 每分钟最多清理一次过期的 session
+func (self *MemorySessionStore) sweep(now time.Time) {
+	if now.Sub(self.lastSweep) < time.Minute {
+		return
+	}
+	self.lastSweep = now
+	for id, item := range self.sessions {
+		if now.After(item.expireAt) {
+			delete(self.sessions, id)
+		}
+	}
+}
+
+// 数据签名后保存在 cookie 中，客户端可以看到但不能修改，不要存放敏感信息
+// keys 用于密钥轮换：用第一个签名，校验时依次尝试
+// cookie 存储无法让旧 cookie 失效，Regenerate、Destroy 之后旧 cookie 在超时前仍然有效
+type CookieSessionStore struct {
+	keys [][]byte
+}
+
+func NewCookieSessionStore(keys ...[]byte) *CookieSessionStore {
+	if len(keys) == 0 {
+		panic("session: cookie store requires at least one key")
+	}
+	return &CookieSessionStore{keys: keys}
+}
+
+func (self *CookieSessionStore) Load(ctx context.Context, cookieValue string) (*SessionData, error) {
+	payload, signature, ok := strings.Cut(cookieValue, ".")
+	if !ok {
+		return nil, nil
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, nil
+	}
+	for _, key := range self.keys {
+		if hmac.Equal(mac, signCookie(key, payload)) {
+			return decodeSessionCookie(payload)
+		}
+	}
+	return nil, nil
+}
+
+func (self *CookieSessionStore) Save(ctx context.Context, data *SessionData, ttl time.Duration) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(encoded)
+	value := payload + "." + base64.RawURLEncoding.EncodeToString(signCookie(self.keys[0], payload))
+	if len(value) > maxCookieSize {
+		return "", ErrSessionTooLarge
+	}
+	return value, nil
+}
+
+func (self *CookieSessionStore) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+func signCookie(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func decodeSessionCookie(payload string) (*SessionData, error) {
+	encoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, nil
+	}
+	data := &SessionData{}
+	if err = json.Unmarshal(encoded, data); err != nil {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// 数据用 AES-GCM 加密后保存在 cookie 中，客户端既不能查看也不能修改
+// key 长度为 16、24 或 32 字节，用第一个加密，解密时依次尝试
+type EncryptedCookieSessionStore struct {
+	aeads []cipher.AEAD
+}
+
+func NewEncryptedCookieSessionStore(keys ...[]byte) (*EncryptedCookieSessionStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("session: encrypted cookie store requires at least one key")
+	}
+	store := &EncryptedCookieSessionStore{}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		store.aeads = append(store.aeads, aead)
+	}
+	return store, nil
+}
+
+func (self *EncryptedCookieSessionStore) Load(ctx context.Context, cookieValue string) (*SessionData, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(cookieValue)
+	if err != nil {
+		return nil, nil
+	}
+	for _, aead := range self.aeads {
+		size := aead.NonceSize()
+		if len(sealed) < size {
+			continue
+		}
+		plain, err := aead.Open(nil, sealed[:size], sealed[size:], nil)
+		if err != nil {
+			continue
+		}
+		data := &SessionData{}
+		if json.Unmarshal(plain, data) != nil {
+			return nil, nil
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+func (self *EncryptedCookieSessionStore) Save(ctx context.Context, data *SessionData, ttl time.Duration) (string, error) {
+	plain, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	aead := self.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	value := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil))
+	if len(value) > maxCookieSize {
+		return "", ErrSessionTooLarge
+	}
+	return value, nil
+}
+
+func (self *EncryptedCookieSessionStore) Delete(ctx context.Context, id string) error {
+	return nil
+}
@@ -0,0 +1,175 @@
+// Server-Sent Events
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrStreamClosed = errors.New("event stream closed")
+
+// 事件流，通过 ctx.SSE() 创建
+//
+//	stream, err := ctx.SSE()
+//	if err != nil {
+//		ctx.Error(err)
+//		return
+//	}
+//	defer stream.Close()
+//	stream.Heartbeat(15 * time.Second)
+//	for progress := range ch {
+//		if err := stream.Send("progress", "", progress); err != nil {
+//			return // 客户端已断开
+//		}
+//	}
+type EventStream struct {
+	ctx       *Context
+	flusher   http.Flusher
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// 开始事件流，写出 text/event-stream 响应头。
+// 事件流不受 Timeout 中间件的超时限制，客户端断开（ctx.Done()）后发送会返回错误
+func (ctx *Context) SSE() (*EventStream, error) {
+	flusher, ok := ctx.Resp.responseWriter.(http.Flusher)
+	if !ok {
+		return nil, errors.New("sse: response writer does not support flushing")
+	}
+	ctx.streaming.Store(true)
+	if !ctx.Resp.lock() {
+		return nil, ErrStreamClosed
+	}
+	header := ctx.Resp.responseWriter.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+	ctx.Resp.status = http.StatusOK
+	ctx.Resp.writeStatus()
+	flusher.Flush()
+	ctx.Resp.unlock()
+	return &EventStream{ctx: ctx, flusher: flusher, closed: make(chan struct{})}, nil
+}
+
+// 是否是事件流等长连接响应
+func (ctx *Context) IsStreaming() bool {
+	return ctx.streaming.Load()
+}
+
+// 客户端断线重连时带上的最后一个事件 id
+func (stream *EventStream) LastEventID() string {
+	if id := stream.ctx.request.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return stream.ctx.request.URL.Query().Get("lastEventId")
+}
+
+// 客户端断开或者流被关闭时关闭
+func (stream *EventStream) Done() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-stream.ctx.Done():
+		case <-stream.closed:
+		}
+		close(done)
+	}()
+	return done
+}
+
+// 发送事件，event、id 为空时不输出对应字段；data 为 string、[]byte 时原样输出，其他类型序列化为 json
+func (stream *EventStream) Send(event, id string, data interface{}) error {
+	var payload string
+	switch val := data.(type) {
+	case string:
+		payload = val
+	case []byte:
+		payload = string(val)
+	default:
+		byt, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		payload = string(byt)
+	}
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: " + sseField(id) + "\n")
+	}
+	if event != "" {
+		sb.WriteString("event: " + sseField(event) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(payload, "\r\n", "\n"), "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+	return stream.write(sb.String())
+}
+
+// 告诉客户端断线后多久重连
+func (stream *EventStream) Retry(d time.Duration) error {
+	return stream.write(fmt.Sprintf("retry: %d\n\n", d.Milliseconds()))
+}
+
+// 注释行，客户端会忽略，可用于保持连接
+func (stream *EventStream) Comment(text string) error {
+	return stream.write(": " + sseField(text) + "\n\n")
+}
+
+// 定时发送心跳注释，防止代理因连接空闲断开，流关闭或客户端断开后自动停止
+func (stream *EventStream) Heartbeat(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if stream.Comment("heartbeat") != nil {
+					return
+				}
+			case <-stream.ctx.Done():
+				return
+			case <-stream.closed:
+				return
+			}
+		}
+	}()
+}
+
+// 结束事件流，停止心跳
+func (stream *EventStream) Close() {
+	stream.closeOnce.Do(func() {
+		close(stream.closed)
+	})
+}
+
+func (stream *EventStream) write(msg string) error {
+	select {
+	case <-stream.closed:
+		return ErrStreamClosed
+	case <-stream.ctx.Done():
+		return stream.ctx.Err()
+	default:
+	}
+	stream.ctx.WriterMux().Lock()
+	defer stream.ctx.WriterMux().Unlock()
+	if stream.ctx.HasTimeout() {
+		return ErrStreamClosed
+	}
+	if _, err := stream.ctx.Resp.responseWriter.Write([]byte(msg)); err != nil {
+		return err
+	}
+	stream.flusher.Flush()
+	return nil
+}
+
+// id、event 等字段不能包含换行
+func sseField(val string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(val)
+}
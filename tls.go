@@ -0,0 +1,326 @@
+// TLS、证书热加载与 HTTP/2 配置
+package httpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/textthree/provider"
+	"github.com/textthree/provider/config"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLS 配置，CertFile、KeyFile 都不为空时启用 https
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// 最低 TLS 版本，0 表示 TLS 1.2
+	MinVersion uint16
+	// 允许的加密套件，为空时使用 Go 默认值（TLS 1.3 的套件不可配置）
+	CipherSuites []uint16
+	// 客户端证书 CA，不为空时启用 mTLS
+	ClientCAFile string
+	// 客户端证书校验方式，配置了 ClientCAFile 且为 tls.NoClientCert 时使用 tls.RequireAndVerifyClientCert
+	ClientAuth tls.ClientAuthType
+	// 检查证书文件是否变更的间隔，0 表示默认 10s，小于 0 表示不热加载
+	ReloadInterval time.Duration
+	// 不为空时在该地址启动一个把 http 请求 301 到 https 的服务，如 ":80"
+	RedirectAddr string
+}
+
+// HTTP/2 配置
+type HTTP2Config struct {
+	// 关闭 HTTP/2，只使用 HTTP/1.1
+	Disable bool
+	// 不使用 TLS 的 HTTP/2（h2c），用于内部服务网格
+	H2C bool
+	// 每个连接的最大并发流，0 表示默认 250
+	MaxConcurrentStreams uint32
+	// 最大帧大小，0 表示默认 16KB
+	MaxReadFrameSize uint32
+	// 空闲连接超时，0 表示使用 http.Server 的 IdleTimeout
+	IdleTimeout time.Duration
+}
+
+const defaultCertReloadInterval = 10 * time.Second
+
+func (self TLSConfig) Enabled() bool {
+	return self.CertFile != "" && self.KeyFile != ""
+}
+
+// 修改 TLS 配置
+func (self *Engine) SetTLSConfig(cfg TLSConfig) {
+	self.tlsConfig = cfg
+}
+
+func (self *Engine) TLSConfig() TLSConfig {
+	return self.tlsConfig
+}
+
+// 修改 HTTP/2 配置
+func (self *Engine) SetHTTP2Config(cfg HTTP2Config) {
+	self.http2Config = cfg
+}
+
+func (self *Engine) HTTP2Config() HTTP2Config {
+	return self.http2Config
+}
+
+// 从 http.tls.*、http.http2.* 读取配置
+func loadTLSConfig(cfg config.Service) (TLSConfig, error) {
+	tlsCfg := TLSConfig{
+		ReloadInterval: configDuration(cfg, "http.tls.reloadInterval"),
+	}
+	if val, ok := configValue(cfg, "http.tls.certFile"); ok {
+		tlsCfg.CertFile = cast.ToString(val)
+	}
+	if val, ok := configValue(cfg, "http.tls.keyFile"); ok {
+		tlsCfg.KeyFile = cast.ToString(val)
+	}
+	if val, ok := configValue(cfg, "http.tls.clientCAFile"); ok {
+		tlsCfg.ClientCAFile = cast.ToString(val)
+	}
+	if val, ok := configValue(cfg, "http.tls.redirectAddr"); ok {
+		tlsCfg.RedirectAddr = cast.ToString(val)
+	}
+	if val, ok := configValue(cfg, "http.tls.minVersion"); ok {
+		version, err := ParseTLSVersion(cast.ToString(val))
+		if err != nil {
+			return tlsCfg, err
+		}
+		tlsCfg.MinVersion = version
+	}
+	if val, ok := configValue(cfg, "http.tls.cipherSuites"); ok {
+		suites, err := ParseCipherSuites(cast.ToStringSlice(val)...)
+		if err != nil {
+			return tlsCfg, err
+		}
+		tlsCfg.CipherSuites = suites
+	}
+	if val, ok := configValue(cfg, "http.tls.clientAuth"); ok {
+		auth, err := ParseClientAuth(cast.ToString(val))
+		if err != nil {
+			return tlsCfg, err
+		}
+		tlsCfg.ClientAuth = auth
+	}
+	return tlsCfg, nil
+}
+
+func loadHTTP2Config(cfg config.Service) HTTP2Config {
+	http2Cfg := HTTP2Config{
+		IdleTimeout: configDuration(cfg, "http.http2.idleTimeout"),
+	}
+	if val, ok := configValue(cfg, "http.http2.disable"); ok {
+		http2Cfg.Disable = cast.ToBool(val)
+	}
+	if val, ok := configValue(cfg, "http.http2.h2c"); ok {
+		http2Cfg.H2C = cast.ToBool(val)
+	}
+	if val, ok := configValue(cfg, "http.http2.maxConcurrentStreams"); ok {
+		http2Cfg.MaxConcurrentStreams = cast.ToUint32(val)
+	}
+	http2Cfg.MaxReadFrameSize = uint32(configByteSize(cfg, "http.http2.maxReadFrameSize"))
+	return http2Cfg
+}
+
+// "1.0"、"1.1"、"1.2"、"1.3"，也支持 "TLS1.2"、"tls13" 这样的写法
+func ParseTLSVersion(s string) (uint16, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	v = strings.TrimPrefix(strings.TrimPrefix(v, "tls"), "v")
+	switch strings.Replace(v, ".", "", 1) {
+	case "10":
+		return tls.VersionTLS10, nil
+	case "11":
+		return tls.VersionTLS11, nil
+	case "12":
+		return tls.VersionTLS12, nil
+	case "13":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown tls version: %q", s)
+}
+
+// 按名称解析加密套件，如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+func ParseCipherSuites(names ...string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls cipher suite: %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// "none"、"request"、"require"、"verify_if_given"、"require_and_verify"
+func ParseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(strings.Replace(strings.TrimSpace(s), "-", "_", -1)) {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require_and_verify":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("unknown tls client auth: %q", s)
+}
+
+// 根据 TLSConfig 生成 *tls.Config，证书通过 CertReloader 提供，调用方负责 Close
+func (self TLSConfig) Build() (*tls.Config, *CertReloader, error) {
+	if !self.Enabled() {
+		return nil, nil, errors.New("tls cert file and key file are required")
+	}
+	reloader, err := NewCertReloader(self.CertFile, self.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion:     self.MinVersion,
+		CipherSuites:   self.CipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if self.ClientCAFile != "" {
+		pem, err := os.ReadFile(self.ClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificate found in client ca: %s", self.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = self.ClientAuth
+		if cfg.ClientAuth == tls.NoClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	interval := self.ReloadInterval
+	if interval == 0 {
+		interval = defaultCertReloadInterval
+	}
+	if interval > 0 {
+		reloader.Watch(interval)
+	}
+	return cfg, reloader, nil
+}
+
+// 证书热加载，定时检查证书文件修改时间，变更后重新加载，无需重启服务
+// 新证书加载失败时记录日志并继续使用旧证书
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	stop     chan struct{}
+	once     sync.Once
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	reloader := &CertReloader{certFile: certFile, keyFile: keyFile, stop: make(chan struct{})}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// 重新加载证书
+func (self *CertReloader) Reload() error {
+	modTime, err := self.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(self.certFile, self.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls certificate: %w", err)
+	}
+	self.mu.Lock()
+	self.cert = &cert
+	self.modTime = modTime
+	self.mu.Unlock()
+	return nil
+}
+
+// 证书和私钥中较新的修改时间，证书轮换时两个文件通常先后写入
+func (self *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{self.certFile, self.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// 用于 tls.Config.GetCertificate
+func (self *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.cert, nil
+}
+
+// 开始定时检查证书文件
+func (self *CertReloader) Watch(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-self.stop:
+				return
+			case <-ticker.C:
+				self.reloadIfChanged()
+			}
+		}
+	}()
+}
+
+func (self *CertReloader) reloadIfChanged() {
+	modTime, err := self.latestModTime()
+	if err != nil {
+		provider.Clog().Error("[Reload tls certificate fail]", err)
+		return
+	}
+	self.mu.RLock()
+	changed := !modTime.Equal(self.modTime)
+	self.mu.RUnlock()
+	if !changed {
+		return
+	}
+	if err := self.Reload(); err != nil {
+		provider.Clog().Error("[Reload tls certificate fail]", err)
+		return
+	}
+	provider.Clog().Trace("Reload tls certificate:", self.certFile)
+}
+
+// 停止检查证书文件
+func (self *CertReloader) Close() error {
+	self.once.Do(func() { close(self.stop) })
+	return nil
+}
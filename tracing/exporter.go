@@ -0,0 +1,287 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 每个 span 输出一行 JSON，适合开发调试或由日志采集导出
+type StdoutExporter struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// writer 为 nil 时输出到 os.Stdout
+func NewStdoutExporter(writer io.Writer) *StdoutExporter {
+	if writer == nil {
+		writer = os.Stdout
+	}
+	return &StdoutExporter{writer: writer}
+}
+
+type stdoutSpan struct {
+	TraceID      string                 `json:"traceId"`
+	SpanID       string                 `json:"spanId"`
+	ParentSpanID string                 `json:"parentSpanId,omitempty"`
+	TraceState   string                 `json:"traceState,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	StartTime    time.Time              `json:"startTime"`
+	EndTime      time.Time              `json:"endTime"`
+	Duration     string                 `json:"duration"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Events       []Event                `json:"events,omitempty"`
+	Status       string                 `json:"status"`
+	StatusMsg    string                 `json:"statusMessage,omitempty"`
+}
+
+func (self *StdoutExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	encoder := json.NewEncoder(self.writer)
+	for _, span := range spans {
+		item := stdoutSpan{
+			TraceID:    span.SpanContext.TraceID.String(),
+			SpanID:     span.SpanContext.SpanID.String(),
+			TraceState: span.SpanContext.TraceState,
+			Name:       span.Name,
+			Kind:       span.Kind.String(),
+			StartTime:  span.StartTime,
+			EndTime:    span.EndTime,
+			Duration:   span.EndTime.Sub(span.StartTime).String(),
+			Attributes: span.Attributes,
+			Events:     span.Events,
+			Status:     span.StatusCode.String(),
+			StatusMsg:  span.StatusMessage,
+		}
+		if span.ParentSpanID.IsValid() {
+			item.ParentSpanID = span.ParentSpanID.String()
+		}
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *StdoutExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// 以 OTLP/HTTP 的 JSON 编码发送到采集器，如 OpenTelemetry Collector、Jaeger、Tempo
+type OTLPExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+}
+
+type OTLPConfig struct {
+	// 完整地址，默认 http://localhost:4318/v1/traces
+	Endpoint string
+	// 附加请求头，如鉴权
+	Headers map[string]string
+	// 资源属性 service.name
+	ServiceName string
+	// 请求超时，0 表示默认 10s
+	Timeout time.Duration
+}
+
+func NewOTLPExporter(cfg OTLPConfig) *OTLPExporter {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "http://localhost:4318/v1/traces"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &OTLPExporter{
+		endpoint:    cfg.Endpoint,
+		headers:     cfg.Headers,
+		serviceName: cfg.ServiceName,
+		client:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (self *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(self.payload(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, self.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, val := range self.headers {
+		req.Header.Set(key, val)
+	}
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp export: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("otlp export: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (self *OTLPExporter) Shutdown(ctx context.Context) error {
+	self.client.CloseIdleConnections()
+	return nil
+}
+
+// OTLP JSON 结构，trace id、span id 使用十六进制，时间使用字符串形式的纳秒
+type otlpPayload struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpValue `json:"values"`
+}
+
+func (self *OTLPExporter) payload(spans []SpanData) otlpPayload {
+	list := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		item := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			TraceState:        span.SpanContext.TraceState,
+			Name:              span.Name,
+			Kind:              int(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: int(span.StatusCode), Message: span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			item.ParentSpanID = span.ParentSpanID.String()
+		}
+		for _, event := range span.Events {
+			item.Events = append(item.Events, otlpEvent{
+				TimeUnixNano: strconv.FormatInt(event.Time.UnixNano(), 10),
+				Name:         event.Name,
+				Attributes:   otlpAttributes(event.Attributes),
+			})
+		}
+		list = append(list, item)
+	}
+	return otlpPayload{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]interface{}{"service.name": self.serviceName})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/textthree/cvgoweb/tracing"}, Spans: list}},
+	}}}
+}
+
+// 按 key 排序，输出稳定
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	list := make([]otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, otlpKeyValue{Key: key, Value: toOTLPValue(attributes[key])})
+	}
+	return list
+}
+
+func toOTLPValue(value interface{}) otlpValue {
+	switch v := value.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	case []string:
+		arr := &otlpArrayValue{}
+		for _, item := range v {
+			arr.Values = append(arr.Values, toOTLPValue(item))
+		}
+		return otlpValue{ArrayValue: arr}
+	case []int64:
+		arr := &otlpArrayValue{}
+		for _, item := range v {
+			arr.Values = append(arr.Values, toOTLPValue(item))
+		}
+		return otlpValue{ArrayValue: arr}
+	}
+	s := fmt.Sprint(value)
+	return otlpValue{StringValue: &s}
+}
@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 本地模拟的 OTLP/HTTP 采集器，记录收到的请求
+type collector struct {
+	*httptest.Server
+	requests chan *http.Request
+	bodies   chan []byte
+	status   int
+}
+
+func newCollector(status int) *collector {
+	c := &collector{requests: make(chan *http.Request, 10), bodies: make(chan []byte, 10), status: status}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.requests <- r
+		c.bodies <- body
+		w.WriteHeader(c.status)
+	}))
+	return c
+}
+
+func TestOTLPExporterExportSpans(t *testing.T) {
+	c := newCollector(http.StatusOK)
+	defer c.Close()
+	exporter := NewOTLPExporter(OTLPConfig{Endpoint: c.URL + "/v1/traces", ServiceName: "orders", Headers: map[string]string{"Authorization": "Bearer token"}})
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	start := time.Unix(1700000000, 5)
+	span := SpanData{
+		Name:          "GET /orders/:id",
+		Kind:          SpanKindServer,
+		SpanContext:   SpanContext{TraceID: parent.TraceID, SpanID: SpanID{1, 2, 3, 4, 5, 6, 7, 8}, Flags: flagSampled, TraceState: "vendor=1"},
+		ParentSpanID:  parent.SpanID,
+		StartTime:     start,
+		EndTime:       start.Add(time.Millisecond),
+		Attributes:    map[string]interface{}{"http.status_code": 500, "http.route": "/orders/:id", "retry": true, "ratio": 0.5},
+		Events:        []Event{{Name: "exception", Time: start, Attributes: map[string]interface{}{"exception.message": "boom"}}},
+		StatusCode:    StatusError,
+		StatusMessage: "boom",
+	}
+	if err := exporter.ExportSpans(context.Background(), []SpanData{span}); err != nil {
+		t.Fatal(err)
+	}
+	r := <-c.requests
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+		t.Fatalf("request = %s %s", r.Method, r.URL.Path)
+	}
+	if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+		t.Fatalf("headers = %v", r.Header)
+	}
+	var payload otlpPayload
+	if err := json.Unmarshal(<-c.bodies, &payload); err != nil {
+		t.Fatal(err)
+	}
+	resource := payload.ResourceSpans[0]
+	if got := *resource.Resource.Attributes[0].Value.StringValue; got != "orders" {
+		t.Fatalf("service.name = %q", got)
+	}
+	got := resource.ScopeSpans[0].Spans[0]
+	if got.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || got.ParentSpanID != "00f067aa0ba902b7" || got.SpanID != "0102030405060708" {
+		t.Fatalf("ids = %s %s %s", got.TraceID, got.ParentSpanID, got.SpanID)
+	}
+	if got.Kind != int(SpanKindServer) || got.StartTimeUnixNano != "1700000000000000005" || got.TraceState != "vendor=1" {
+		t.Fatalf("span = %+v", got)
+	}
+	if got.Status.Code != int(StatusError) || got.Status.Message != "boom" {
+		t.Fatalf("status = %+v", got.Status)
+	}
+	// 属性按 key 排序，数字以字符串形式的 intValue 输出
+	keys := []string{}
+	for _, kv := range got.Attributes {
+		keys = append(keys, kv.Key)
+	}
+	if strings.Join(keys, ",") != "http.route,http.status_code,ratio,retry" {
+		t.Fatalf("attribute keys = %v", keys)
+	}
+	if got.Attributes[1].Value.IntValue == nil || *got.Attributes[1].Value.IntValue != "500" {
+		t.Fatalf("intValue = %+v", got.Attributes[1].Value)
+	}
+	if got.Attributes[2].Value.DoubleValue == nil || got.Attributes[3].Value.BoolValue == nil {
+		t.Fatalf("typed values = %+v", got.Attributes)
+	}
+	if len(got.Events) != 1 || got.Events[0].Name != "exception" {
+		t.Fatalf("events = %+v", got.Events)
+	}
+}
+
+func TestOTLPExporterErrorStatus(t *testing.T) {
+	c := newCollector(http.StatusServiceUnavailable)
+	defer c.Close()
+	exporter := NewOTLPExporter(OTLPConfig{Endpoint: c.URL})
+	err := exporter.ExportSpans(context.Background(), []SpanData{{Name: "op"}})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("got %v, want unexpected status error", err)
+	}
+	if err = exporter.ExportSpans(context.Background(), nil); err != nil {
+		t.Fatalf("empty batch: %v", err)
+	}
+}
+
+func TestTracerExportsThroughOTLP(t *testing.T) {
+	c := newCollector(http.StatusOK)
+	defer c.Close()
+	tracer := NewTracer(Config{ServiceName: "orders", Exporter: NewOTLPExporter(OTLPConfig{Endpoint: c.URL})})
+	_, span := tracer.Start(context.Background(), "op")
+	span.SetStatus(StatusCode(42), "ignored")
+	span.End()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	body := <-c.bodies
+	if !bytes.Contains(body, []byte(`"name":"op"`)) {
+		t.Fatalf("payload = %s", body)
+	}
+}
+
+func TestStdoutExporterUnknownStatus(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := NewStdoutExporter(&buf)
+	if err := exporter.ExportSpans(context.Background(), []SpanData{{Name: "op", StatusCode: StatusCode(7)}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"status":"unset"`) {
+		t.Fatalf("output = %s", buf.String())
+	}
+}
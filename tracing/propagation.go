@@ -0,0 +1,145 @@
+// W3C Trace Context（traceparent / tracestate）的解析与传播
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+const flagSampled = 0x01
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (self TraceID) String() string {
+	return hex.EncodeToString(self[:])
+}
+
+func (self TraceID) IsValid() bool {
+	return self != TraceID{}
+}
+
+func (self SpanID) String() string {
+	return hex.EncodeToString(self[:])
+}
+
+func (self SpanID) IsValid() bool {
+	return self != SpanID{}
+}
+
+// 跨进程传播的 span 标识
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	Remote     bool // 是否从请求头解析得到
+}
+
+func (self SpanContext) IsValid() bool {
+	return self.TraceID.IsValid() && self.SpanID.IsValid()
+}
+
+func (self SpanContext) IsSampled() bool {
+	return self.Flags&flagSampled != 0
+}
+
+// traceparent 格式：00-<trace-id>-<span-id>-<flags>
+func (self SpanContext) Traceparent() string {
+	return "00-" + self.TraceID.String() + "-" + self.SpanID.String() + "-" + hex.EncodeToString([]byte{self.Flags})
+}
+
+var ErrInvalidTraceparent = errors.New("tracing: invalid traceparent")
+
+// 解析 traceparent，未知的高版本按 00 的格式兼容解析
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	value = strings.TrimSpace(value)
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return sc, ErrInvalidTraceparent
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff {
+		return sc, ErrInvalidTraceparent
+	}
+	// 版本 00 必须正好 4 段
+	if version[0] == 0 && len(parts) != 4 {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 || !isLowerHex(parts[1]+parts[2]+parts[3]) {
+		return sc, ErrInvalidTraceparent
+	}
+	hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	flags, _ := hex.DecodeString(parts[3])
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	sc.Remote = true
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// 从请求头读取上游的 span，没有或无效时返回零值
+func Extract(header http.Header) SpanContext {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}
+	}
+	sc.TraceState = normalizeTracestate(header.Values(TracestateHeader))
+	return sc
+}
+
+// 把 ctx 中的 span 写入请求头，用于调用下游服务
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+// 合并多个 tracestate 头，去掉空项，最多保留 32 项
+func normalizeTracestate(values []string) string {
+	var members []string
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			member = strings.TrimSpace(member)
+			if member == "" || !strings.Contains(member, "=") {
+				continue
+			}
+			members = append(members, member)
+			if len(members) == 32 {
+				return strings.Join(members, ",")
+			}
+		}
+	}
+	return strings.Join(members, ",")
+}
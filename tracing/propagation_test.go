@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"future version with extra fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"version 00 with extra fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"uppercase hex", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"short trace id", "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if tt.valid != (err == nil) {
+				t.Fatalf("ParseTraceparent(%q) error = %v, want valid %v", tt.value, err, tt.valid)
+			}
+			if tt.valid && (!sc.Remote || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736") {
+				t.Fatalf("span context = %+v", sc)
+			}
+		})
+	}
+}
+
+func TestExtractInject(t *testing.T) {
+	incoming := http.Header{}
+	incoming.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	incoming.Add(TracestateHeader, "rojo=00f067aa0ba902b7, ,invalid")
+	incoming.Add(TracestateHeader, "congo=t61rcWkgMzE")
+	parent := Extract(incoming)
+	if !parent.IsSampled() || parent.TraceState != "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE" {
+		t.Fatalf("extracted = %+v", parent)
+	}
+
+	tracer := NewTracer(Config{})
+	defer tracer.Shutdown(context.Background())
+	ctx, span := tracer.Start(context.Background(), "op", StartOptions{Parent: parent})
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	sc := span.SpanContext()
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + sc.SpanID.String() + "-01"
+	if got := outgoing.Get(TraceparentHeader); got != want {
+		t.Fatalf("traceparent = %q, want %q", got, want)
+	}
+	if sc.SpanID == parent.SpanID {
+		t.Fatal("child span reused the parent span id")
+	}
+	if got := outgoing.Get(TracestateHeader); got != parent.TraceState {
+		t.Fatalf("tracestate = %q", got)
+	}
+
+	// 没有 span 时不写入
+	empty := http.Header{}
+	Inject(context.Background(), empty)
+	if len(empty) != 0 {
+		t.Fatalf("headers = %v", empty)
+	}
+}
@@ -0,0 +1,191 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// span 类型，取值与 OTLP 一致
+type SpanKind int
+
+const (
+	SpanKindUnspecified SpanKind = iota
+	SpanKindInternal
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+func (self SpanKind) String() string {
+	switch self {
+	case SpanKindInternal:
+		return "internal"
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	case SpanKindProducer:
+		return "producer"
+	case SpanKindConsumer:
+		return "consumer"
+	}
+	return "unspecified"
+}
+
+// span 状态，取值与 OTLP 一致
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+func (self StatusCode) String() string {
+	switch self {
+	case StatusOK:
+		return "ok"
+	case StatusError:
+		return "error"
+	}
+	return "unset"
+}
+
+type Event struct {
+	Name       string                 `json:"name"`
+	Time       time.Time              `json:"time"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// 一次操作的耗时记录
+type Span struct {
+	tracer        *Tracer
+	mu            sync.Mutex
+	name          string
+	kind          SpanKind
+	spanContext   SpanContext
+	parentSpanID  SpanID
+	startTime     time.Time
+	endTime       time.Time
+	attributes    map[string]interface{}
+	events        []Event
+	statusCode    StatusCode
+	statusMessage string
+	ended         bool
+}
+
+// 导出时使用的只读快照
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    map[string]interface{}
+	Events        []Event
+	StatusCode    StatusCode
+	StatusMessage string
+}
+
+func (self *Span) SpanContext() SpanContext {
+	return self.spanContext
+}
+
+// 修改名称，如路由匹配后改为路由模式
+func (self *Span) SetName(name string) {
+	self.mu.Lock()
+	self.name = name
+	self.mu.Unlock()
+}
+
+// 属性值支持 string、bool、int、int64、float64 及其切片
+func (self *Span) SetAttribute(key string, value interface{}) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.ended {
+		return
+	}
+	if self.attributes == nil {
+		self.attributes = map[string]interface{}{}
+	}
+	self.attributes[key] = value
+}
+
+func (self *Span) AddEvent(name string, attributes map[string]interface{}) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.ended {
+		return
+	}
+	self.events = append(self.events, Event{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+// 不在 StatusUnset、StatusOK、StatusError 中的值会被忽略
+func (self *Span) SetStatus(code StatusCode, message string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.ended || code < StatusUnset || code > StatusError {
+		return
+	}
+	self.statusCode = code
+	if code == StatusError {
+		self.statusMessage = message
+	}
+}
+
+// 记录错误事件并把状态设为 error
+func (self *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	self.AddEvent("exception", map[string]interface{}{"exception.message": err.Error()})
+	self.SetStatus(StatusError, err.Error())
+}
+
+// 结束 span，采样的 span 交给导出器，重复调用无效
+func (self *Span) End() {
+	self.mu.Lock()
+	if self.ended {
+		self.mu.Unlock()
+		return
+	}
+	self.ended = true
+	self.endTime = time.Now()
+	self.mu.Unlock()
+	if self.tracer != nil && self.spanContext.IsSampled() {
+		self.tracer.export(self.data())
+	}
+}
+
+func (self *Span) data() SpanData {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return SpanData{
+		Name:          self.name,
+		Kind:          self.kind,
+		SpanContext:   self.spanContext,
+		ParentSpanID:  self.parentSpanID,
+		StartTime:     self.startTime,
+		EndTime:       self.endTime,
+		Attributes:    self.attributes,
+		Events:        self.events,
+		StatusCode:    self.statusCode,
+		StatusMessage: self.statusMessage,
+	}
+}
+
+type spanKey struct{}
+
+// 把 span 放入 context，传给下游的 ctx 可以继续这条链路
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// 取出 ctx 中当前的 span，没有时返回 nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
@@ -0,0 +1,221 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// span 导出器
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer 配置
+type Config struct {
+	ServiceName string
+	Exporter    Exporter
+	// 没有上游 span 时的采样比例，0 表示默认 1（全部采样）。有上游 span 时沿用上游的采样标记
+	SampleRatio float64
+	// 批量导出的最大条数，0 表示默认 512
+	BatchSize int
+	// 批量导出的间隔，0 表示默认 5s
+	BatchInterval time.Duration
+	// 等待导出的最大条数，超出时丢弃，0 表示默认 2048
+	QueueSize int
+	// 导出出错时回调，默认忽略
+	OnError func(err error)
+}
+
+type Tracer struct {
+	config   Config
+	queue    chan SpanData
+	flush    chan chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type StartOptions struct {
+	Kind       SpanKind
+	Attributes map[string]interface{}
+	// 不为零值时作为父 span，通常来自请求头
+	Parent SpanContext
+}
+
+func NewTracer(cfg Config) *Tracer {
+	if cfg.SampleRatio <= 0 {
+		cfg.SampleRatio = 1
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.BatchInterval <= 0 {
+		cfg.BatchInterval = 5 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 2048
+	}
+	tracer := &Tracer{
+		config: cfg,
+		queue:  make(chan SpanData, cfg.QueueSize),
+		flush:  make(chan chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go tracer.loop()
+	return tracer
+}
+
+func (self *Tracer) ServiceName() string {
+	return self.config.ServiceName
+}
+
+// 开始一个 span，ctx 中有 span 时作为父 span，返回的 ctx 包含新 span
+func (self *Tracer) Start(ctx context.Context, name string, opts ...StartOptions) (context.Context, *Span) {
+	var opt StartOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	parent := opt.Parent
+	if !parent.IsValid() {
+		if span := SpanFromContext(ctx); span != nil {
+			parent = span.SpanContext()
+		}
+	}
+	span := &Span{
+		tracer:     self,
+		name:       name,
+		kind:       opt.Kind,
+		startTime:  time.Now(),
+		attributes: opt.Attributes,
+	}
+	if parent.IsValid() {
+		span.spanContext = SpanContext{TraceID: parent.TraceID, Flags: parent.Flags, TraceState: parent.TraceState}
+		span.parentSpanID = parent.SpanID
+	} else {
+		span.spanContext.TraceID = newTraceID()
+		if self.config.SampleRatio >= 1 || rand.Float64() < self.config.SampleRatio {
+			span.spanContext.Flags = flagSampled
+		}
+	}
+	if span.kind == SpanKindUnspecified {
+		span.kind = SpanKindInternal
+	}
+	span.spanContext.SpanID = newSpanID()
+	return ContextWithSpan(ctx, span), span
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+// 队列满时丢弃，不阻塞请求
+func (self *Tracer) export(data SpanData) {
+	if self.config.Exporter == nil {
+		return
+	}
+	select {
+	case <-self.stop:
+	case self.queue <- data:
+	default:
+	}
+}
+
+func (self *Tracer) loop() {
+	defer close(self.done)
+	ticker := time.NewTicker(self.config.BatchInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, self.config.BatchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := self.config.Exporter.ExportSpans(ctx, batch); err != nil && self.config.OnError != nil {
+			self.config.OnError(err)
+		}
+		cancel()
+		batch = make([]SpanData, 0, self.config.BatchSize)
+	}
+	// 取出队列中剩余的 span
+	drain := func() {
+		for {
+			select {
+			case data := <-self.queue:
+				batch = append(batch, data)
+				if len(batch) >= self.config.BatchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case data := <-self.queue:
+			batch = append(batch, data)
+			if len(batch) >= self.config.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-self.flush:
+			drain()
+			close(ack)
+		case <-self.stop:
+			drain()
+			return
+		}
+	}
+}
+
+// 立即导出队列中的 span
+func (self *Tracer) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case self.flush <- ack:
+	case <-self.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 导出剩余的 span 并关闭导出器，可以注册为 engine.OnShutdown(tracer.Shutdown)
+func (self *Tracer) Shutdown(ctx context.Context) error {
+	self.stopOnce.Do(func() { close(self.stop) })
+	select {
+	case <-self.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if self.config.Exporter == nil {
+		return nil
+	}
+	return self.config.Exporter.Shutdown(ctx)
+}
@@ -1,12 +1,33 @@
 package httpserver
 
-import "net/http"
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
 
 type ReqStruct struct {
 	IRequest
 	request *http.Request
+	params  map[string]string // 路由中的路径参数，如 /user/:id
+	// 缓存解析过的 json body，Post* 系列方法共用
+	postData    map[string]interface{}
+	postParsed  bool
+	postErr     error // 读取 body 失败或超出 maxPostSize，控制器执行完后交给错误处理函数
+	maxPostSize int64
+	// 用读取过的内容重新填充 body，由 Context 设置
+	resetBody func(body io.Reader)
+	// 可信代理，用于解析客户端 IP、协议、域名
+	trustedProxies []*net.IPNet
 }
 type RespStruct struct {
 	request        *ReqStruct
 	responseWriter http.ResponseWriter
+	recorder       *responseRecorder // 与 responseWriter 是同一个，用于读取实际写出的状态码和字节数
+	status         int               // SetStatus 设置的状态码，第一次写 body 时才写出
+	wroteHeader    bool              // 状态码是否已经写出
+	writerMux      *sync.Mutex       // 与 Context.WriterMux 是同一把锁
+	hasTimeout     *atomic.Bool      // 超时后丢弃所有写入
 }
@@ -0,0 +1,247 @@
+// 文件上传
+package httpserver
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultMultipartMemory = 32 << 20 // 32 MB，超出部分写入临时文件
+
+var (
+	ErrFileTooLarge      = NewHTTPError(http.StatusRequestEntityTooLarge, "upload file too large")
+	ErrFileTypeForbidden = NewHTTPError(http.StatusUnsupportedMediaType, "upload file type not allowed")
+	ErrTooManyFiles      = NewHTTPError(http.StatusRequestEntityTooLarge, "too many upload files")
+)
+
+// 上传文件限制，零值表示不限制
+type UploadLimits struct {
+	MaxFileSize  int64    // 单个文件最大字节数
+	MaxFiles     int      // 最多文件个数
+	AllowedExts  []string // 允许的扩展名，如 ".jpg"、".png"，不区分大小写
+	AllowedMimes []string // 允许的 mime 类型，根据文件内容嗅探而不是客户端声明，支持 "image/*"
+}
+
+// 检查已经解析好的上传文件
+func (limits UploadLimits) Check(header *multipart.FileHeader) error {
+	if limits.MaxFileSize > 0 && header.Size > limits.MaxFileSize {
+		return ErrFileTooLarge
+	}
+	if !limits.allowExt(header.Filename) {
+		return ErrFileTypeForbidden
+	}
+	if len(limits.AllowedMimes) == 0 {
+		return nil
+	}
+	f, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if !limits.allowMime(http.DetectContentType(buf[:n])) {
+		return ErrFileTypeForbidden
+	}
+	return nil
+}
+
+func (limits UploadLimits) allowExt(filename string) bool {
+	if len(limits.AllowedExts) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, allowed := range limits.AllowedExts {
+		if strings.ToLower(allowed) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func (limits UploadLimits) allowMime(contentType string) bool {
+	if len(limits.AllowedMimes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range limits.AllowedMimes {
+		allowed = strings.ToLower(allowed)
+		if allowed == mediaType || allowed == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (req *ReqStruct) parseMultipart(maxMemory int64) error {
+	if req.request.MultipartForm != nil {
+		return nil
+	}
+	return req.request.ParseMultipartForm(maxMemory)
+}
+
+// 同一个 key 上传的多个文件
+// limits 可选，传入时每个文件都要通过检查
+func (req *ReqStruct) FormFiles(key string, limits ...UploadLimits) ([]*multipart.FileHeader, error) {
+	if err := req.parseMultipart(defaultMultipartMemory); err != nil {
+		return nil, err
+	}
+	files := req.request.MultipartForm.File[key]
+	if len(files) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	if len(limits) > 0 {
+		if limits[0].MaxFiles > 0 && len(files) > limits[0].MaxFiles {
+			return nil, ErrTooManyFiles
+		}
+		for _, file := range files {
+			if err := limits[0].Check(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// 流式读取 multipart 中的一个部分，文件内容不会整体缓存到内存或临时文件
+type UploadPart struct {
+	FieldName   string
+	FileName    string // 普通表单字段为空
+	ContentType string // 文件内容嗅探得到的类型
+	Header      textproto.MIMEHeader
+	reader      io.Reader
+}
+
+func (part *UploadPart) Read(p []byte) (int, error) {
+	return part.reader.Read(p)
+}
+
+func (part *UploadPart) IsFile() bool {
+	return part.FileName != ""
+}
+
+// 超出大小限制时返回 ErrFileTooLarge
+type limitedPartReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (r *limitedPartReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, ErrFileTooLarge
+	}
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n + int(r.remaining), ErrFileTooLarge
+	}
+	return n, err
+}
+
+// 按顺序流式处理 multipart 请求的每个部分，handler 返回后未读完的内容会被丢弃
+//
+//	err := ctx.Req.StreamMultipart(limits, func(part *httpserver.UploadPart) error {
+//		if !part.IsFile() {
+//			return nil
+//		}
+//		dst, _ := os.Create(filepath.Join(dir, filepath.Base(part.FileName)))
+//		defer dst.Close()
+//		_, err := io.Copy(dst, part)
+//		return err
+//	})
+func (req *ReqStruct) StreamMultipart(limits UploadLimits, handler func(part *UploadPart) error) error {
+	reader, err := req.request.MultipartReader()
+	if err != nil {
+		return err
+	}
+	files := 0
+	for {
+		p, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		part := &UploadPart{
+			FieldName: p.FormName(),
+			FileName:  p.FileName(),
+			Header:    p.Header,
+			reader:    p,
+		}
+		if part.IsFile() {
+			files++
+			if limits.MaxFiles > 0 && files > limits.MaxFiles {
+				p.Close()
+				return ErrTooManyFiles
+			}
+			if !limits.allowExt(part.FileName) {
+				p.Close()
+				return ErrFileTypeForbidden
+			}
+			// 读取前 512 字节嗅探类型，读过的内容仍保留在 bufio 中交给 handler
+			buffered := bufio.NewReaderSize(p, 512)
+			head, _ := buffered.Peek(512)
+			part.ContentType = http.DetectContentType(head)
+			if !limits.allowMime(part.ContentType) {
+				p.Close()
+				return ErrFileTypeForbidden
+			}
+			part.reader = buffered
+			if limits.MaxFileSize > 0 {
+				part.reader = &limitedPartReader{reader: buffered, remaining: limits.MaxFileSize}
+			}
+		}
+		err = handler(part)
+		p.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// 保存上传的文件，目标目录不存在时自动创建
+func (ctx *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err = os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, src)
+	return err
+}
+
+// 请求结束时删除 multipart 解析产生的临时文件
+func (ctx *Context) cleanupMultipart() {
+	if ctx.request.MultipartForm != nil {
+		ctx.request.MultipartForm.RemoveAll()
+	}
+}
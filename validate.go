@@ -0,0 +1,350 @@
+// 基于 tag 的参数校验
+package httpserver
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 校验规则写在 validate tag 中，多个规则用逗号分隔：
+//
+//	type UserDto struct {
+//		Name  string   `json:"name" validate:"required,min=2,max=20"`
+//		Email string   `json:"email" validate:"omitempty,email"`
+//		Role  string   `json:"role" validate:"oneof=admin user"`
+//		Age   int      `json:"age" validate:"gt=0,lt=150"`
+//		Code  string   `json:"code" validate:"len=6,regex=^[0-9]+$"`
+//		Tags  []string `json:"tags" validate:"max=5,dive,required,max=10"`
+//	}
+//
+// dive 之后的规则作用于切片、数组、map 的每个元素；regex 会吃掉后面的全部内容，所以要放在最后。
+// 嵌套结构体（含指针、切片元素）会自动递归校验。
+type FieldError struct {
+	Field   string `json:"field"` // 字段路径，如 user.tags[1]，优先使用 json tag 中的名称
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// 默认提示，i18n 语言包中存在 validate.<rule> 时使用语言包中的模板
+var validateMessages = map[string]string{
+	"required": "{field} is required",
+	"min":      "{field} must be at least {param}",
+	"max":      "{field} must be at most {param}",
+	"len":      "{field} must be exactly {param}",
+	"gt":       "{field} must be greater than {param}",
+	"gte":      "{field} must be greater than or equal to {param}",
+	"lt":       "{field} must be less than {param}",
+	"lte":      "{field} must be less than or equal to {param}",
+	"email":    "{field} must be a valid email address",
+	"oneof":    "{field} must be one of [{param}]",
+	"regex":    "{field} has an invalid format",
+}
+
+var (
+	emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+	regexpCache sync.Map // tag 中的正则只编译一次
+)
+
+type validateRule struct {
+	name  string
+	param string
+}
+
+// 校验结构体，不通过时返回 ValidationErrors
+func Validate(obj any) error {
+	rv := reflect.ValueOf(obj)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, path string, errs *ValidationErrors) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		fieldPath := path
+		if !sf.Anonymous {
+			fieldPath = joinFieldPath(path, fieldName(sf))
+		}
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		rules, err := parseValidateRules(tag)
+		if err != nil {
+			return fmt.Errorf("validate field %s: %w", sf.Name, err)
+		}
+		if err := validateValue(v.Field(i), fieldPath, rules, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fieldName(sf reflect.StructField) string {
+	if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func parseValidateRules(tag string) ([]validateRule, error) {
+	var rules []validateRule
+	for tag != "" {
+		var item string
+		if strings.HasPrefix(tag, "regex=") {
+			item, tag = tag, ""
+		} else if idx := strings.Index(tag, ","); idx >= 0 {
+			item, tag = tag[:idx], tag[idx+1:]
+		} else {
+			item, tag = tag, ""
+		}
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(item, "=")
+		if _, ok := validateMessages[name]; !ok && name != "omitempty" && name != "dive" {
+			return nil, fmt.Errorf("unknown validate rule %q", name)
+		}
+		rules = append(rules, validateRule{name: name, param: param})
+	}
+	return rules, nil
+}
+
+func validateValue(v reflect.Value, path string, rules []validateRule, errs *ValidationErrors) error {
+	for i, rule := range rules {
+		switch rule.name {
+		case "omitempty":
+			if v.IsZero() {
+				return nil
+			}
+			continue
+		case "dive":
+			return validateElems(v, path, rules[i+1:], errs)
+		case "required":
+			if isEmptyValue(v) {
+				errs.add(path, rule)
+				return nil
+			}
+			continue
+		}
+		// 其余规则作用于指针指向的值，nil 指针交给 required 处理
+		elem := v
+		for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+			if elem.IsNil() {
+				return nil
+			}
+			elem = elem.Elem()
+		}
+		ok, err := checkRule(elem, rule)
+		if err != nil {
+			return fmt.Errorf("validate %s: %w", path, err)
+		}
+		if !ok {
+			errs.add(path, rule)
+			return nil
+		}
+	}
+	return validateNested(v, path, errs)
+}
+
+// dive：规则作用于每个元素
+func validateElems(v reflect.Value, path string, rules []validateRule, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), rules, errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := validateValue(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), rules, errs); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("validate %s: dive on non-collection type %s", path, v.Type())
+	}
+	return nil
+}
+
+// 没有规则约束的嵌套结构体也要递归校验
+func validateNested(v reflect.Value, path string, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return nil
+		}
+		return validateStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		elemType := v.Type().Elem()
+		for elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		if elemType.Kind() != reflect.Struct || elemType == timeType {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := validateNested(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func (errs *ValidationErrors) add(path string, rule validateRule) {
+	*errs = append(*errs, FieldError{
+		Field:   path,
+		Rule:    rule.name,
+		Param:   rule.param,
+		Message: formatValidateMessage(validateMessages[rule.name], path, rule.param),
+	})
+}
+
+func formatValidateMessage(tmpl, field, param string) string {
+	return strings.NewReplacer("{field}", field, "{param}", param).Replace(tmpl)
+}
+
+func checkRule(v reflect.Value, rule validateRule) (bool, error) {
+	switch rule.name {
+	case "min", "max", "len", "gt", "gte", "lt", "lte":
+		return compareRule(v, rule)
+	case "email":
+		if v.Kind() != reflect.String {
+			return false, fmt.Errorf("email rule on non-string type %s", v.Type())
+		}
+		return emailRegexp.MatchString(v.String()), nil
+	case "oneof":
+		val := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(rule.param) {
+			if val == option {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "regex":
+		if v.Kind() != reflect.String {
+			return false, fmt.Errorf("regex rule on non-string type %s", v.Type())
+		}
+		re, err := compileRegexp(rule.param)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(v.String()), nil
+	}
+	return true, nil
+}
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexpCache.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.Store(expr, re)
+	return re, nil
+}
+
+// 数值比较大小，字符串比较字符数，切片、map 比较元素个数
+func compareRule(v reflect.Value, rule validateRule) (bool, error) {
+	var actual float64
+	switch v.Kind() {
+	case reflect.String:
+		actual = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual = float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		actual = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = v.Float()
+	default:
+		return false, fmt.Errorf("%s rule on unsupported type %s", rule.name, v.Type())
+	}
+	expected, err := strconv.ParseFloat(rule.param, 64)
+	if err != nil {
+		return false, fmt.Errorf("%s rule param %q: %w", rule.name, rule.param, err)
+	}
+	switch rule.name {
+	case "min", "gte":
+		return actual >= expected, nil
+	case "max", "lte":
+		return actual <= expected, nil
+	case "len":
+		return actual == expected, nil
+	case "gt":
+		return actual > expected, nil
+	case "lt":
+		return actual < expected, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,192 @@
+// WebSocket 路由与握手（RFC 6455）
+package httpserver
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebSocket 控制器，握手成功后调用，返回后连接自动关闭
+type WebSocketHandler func(c *Context, conn *WebSocketConn)
+
+// WebSocket 配置
+type WebSocketConfig struct {
+	// 校验 Origin，为 nil 时只允许同源（Origin 的 host 与请求的 Host 一致）或没有 Origin 头的请求
+	CheckOrigin func(c *Context) bool
+	// 单条消息最大字节数（解压后），0 表示默认 32 MB
+	ReadLimit int64
+	// 客户端支持时启用 permessage-deflate 压缩
+	EnableCompression bool
+	// 服务端支持的子协议，按偏好排序
+	Subprotocols []string
+	// 写超时，0 表示不限制
+	WriteTimeout time.Duration
+}
+
+const defaultWebSocketReadLimit = 32 << 20
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// 修改 WebSocket 配置
+func (self *Engine) SetWebSocketConfig(cfg WebSocketConfig) {
+	self.websocketConfig = cfg
+}
+
+// 注册 WebSocket 路由，和普通路由一样经过全局、分组、路由中间件，可以在中间件中做鉴权
+func (self *Engine) WS(url string, handler WebSocketHandler, middlewares ...MiddlewareHandler) {
+	self.AddRoute("GET", "", url, routeTypeGoStatic, self.websocketRequestHandler(handler), middlewares...)
+}
+
+func (p *Prefix) WS(uri string, handler WebSocketHandler, middlewares ...MiddlewareHandler) {
+	p.httpCore.AddRoute("GET", p.prefix, uri, routeTypeGoGroup, p.httpCore.websocketRequestHandler(handler), middlewares...)
+}
+
+func (self *Engine) websocketRequestHandler(handler WebSocketHandler) RequestHandler {
+	return func(c *Context) {
+		conn, err := c.upgradeWebSocket(self.websocketConfig)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		defer conn.Close()
+		handler(c, conn)
+	}
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Context) checkSameOrigin() bool {
+	origin := c.request.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, c.Host())
+}
+
+// 握手，成功后接管底层 TCP 连接
+func (c *Context) upgradeWebSocket(cfg WebSocketConfig) (*WebSocketConn, error) {
+	r := c.request
+	if r.Method != http.MethodGet {
+		return nil, NewHTTPError(http.StatusMethodNotAllowed, "websocket: method not GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, NewHTTPError(http.StatusBadRequest, "websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.Resp.SetHeader("Sec-WebSocket-Version", "13")
+		return nil, NewHTTPError(http.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, NewHTTPError(http.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := cfg.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = (*Context).checkSameOrigin
+	}
+	if !checkOrigin(c) {
+		return nil, NewHTTPError(http.StatusForbidden, "websocket: origin not allowed")
+	}
+	hijacker, ok := c.Resp.responseWriter.(http.Hijacker)
+	if !ok {
+		return nil, NewHTTPError(http.StatusInternalServerError, "websocket: response does not support hijacking")
+	}
+
+	subprotocol := selectSubprotocol(r.Header, cfg.Subprotocols)
+	compress := cfg.EnableCompression && clientSupportsDeflate(r.Header)
+
+	// 超时中间件已经写出响应时不能再接管连接
+	if !c.Resp.lock() {
+		return nil, http.ErrHandlerTimeout
+	}
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		c.Resp.unlock()
+		return nil, err
+	}
+	// 连接已被接管，不能再通过 ResponseWriter 输出
+	c.Resp.wroteHeader = true
+	c.streaming.Store(true)
+	c.Resp.unlock()
+
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	sb.WriteString("Sec-WebSocket-Accept: " + websocketAcceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		sb.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		// 不保留压缩上下文，每条消息独立压缩，节省每个连接常驻的字典内存
+		sb.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	sb.WriteString("\r\n")
+	// 握手前请求设置过的读写超时需要清除
+	netConn.SetDeadline(time.Time{})
+	if _, err := netConn.Write([]byte(sb.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	readLimit := cfg.ReadLimit
+	if readLimit <= 0 {
+		readLimit = defaultWebSocketReadLimit
+	}
+	reader := brw.Reader
+	if reader == nil {
+		reader = bufio.NewReader(netConn)
+	}
+	return &WebSocketConn{
+		conn:         netConn,
+		reader:       reader,
+		readLimit:    readLimit,
+		compress:     compress,
+		subprotocol:  subprotocol,
+		writeTimeout: cfg.WriteTimeout,
+	}, nil
+}
+
+func websocketAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// 按服务端的偏好顺序选择客户端也支持的子协议
+func selectSubprotocol(header http.Header, supported []string) string {
+	for _, protocol := range supported {
+		if headerContainsToken(header, "Sec-WebSocket-Protocol", protocol) {
+			return protocol
+		}
+	}
+	return ""
+}
+
+func clientSupportsDeflate(header http.Header) bool {
+	for _, value := range header.Values("Sec-WebSocket-Extensions") {
+		for _, extension := range strings.Split(value, ",") {
+			name := strings.TrimSpace(strings.Split(extension, ";")[0])
+			if strings.EqualFold(name, "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
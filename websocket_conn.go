@@ -0,0 +1,430 @@
+// WebSocket 连接：帧读写、分片、控制帧、permessage-deflate
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型，与帧的 opcode 一致
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// 关闭状态码，见 RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const maxControlFramePayload = 125
+
+// 收到关闭帧或者因协议错误关闭连接时返回
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+var ErrWebSocketClosed = errors.New("websocket: connection closed")
+
+type WebSocketConn struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	readLimit    int64
+	compress     bool // 是否协商了 permessage-deflate
+	subprotocol  string
+	writeTimeout time.Duration
+
+	writeMux  sync.Mutex
+	closeSent bool
+	closeOnce sync.Once
+
+	pingHandler func(data []byte) error
+	pongHandler func(data []byte) error
+}
+
+// 协商得到的子协议
+func (ws *WebSocketConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+func (ws *WebSocketConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// 单条消息最大字节数，超出时以 1009 关闭连接，小于等于 0 时使用默认的 32 MB，不能取消限制
+func (ws *WebSocketConn) SetReadLimit(limit int64) {
+	if limit <= 0 {
+		limit = defaultWebSocketReadLimit
+	}
+	ws.readLimit = limit
+}
+
+func (ws *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// 收到 ping 时的处理，默认回复 pong
+func (ws *WebSocketConn) SetPingHandler(handler func(data []byte) error) {
+	ws.pingHandler = handler
+}
+
+// 收到 pong 时的处理，常用于延长读超时
+func (ws *WebSocketConn) SetPongHandler(handler func(data []byte) error) {
+	ws.pongHandler = handler
+}
+
+// 读取一条完整消息，分片消息会被拼接，压缩消息会被解压。
+// ping、pong 在内部处理；收到关闭帧时回复关闭帧并返回 *CloseError
+func (ws *WebSocketConn) ReadMessage() (int, []byte, error) {
+	var (
+		messageType int
+		compressed  bool
+		message     bytes.Buffer
+	)
+	for {
+		frame, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch frame.opcode {
+		case PingMessage:
+			if err := ws.handlePing(frame.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if ws.pongHandler != nil {
+				if err := ws.pongHandler(frame.payload); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, ws.handleClose(frame.payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.failConnection(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = frame.opcode
+			compressed = frame.rsv1
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.failConnection(CloseProtocolError, "unexpected continuation frame")
+			}
+			if frame.rsv1 {
+				return 0, nil, ws.failConnection(CloseProtocolError, "rsv1 set on continuation frame")
+			}
+		default:
+			return 0, nil, ws.failConnection(CloseProtocolError, fmt.Sprintf("unknown opcode %d", frame.opcode))
+		}
+		if int64(message.Len()+len(frame.payload)) > ws.readLimit {
+			return 0, nil, ws.failConnection(CloseMessageTooBig, "message too big")
+		}
+		message.Write(frame.payload)
+		if !frame.fin {
+			continue
+		}
+		data := message.Bytes()
+		if compressed {
+			if data, err = ws.inflate(data); err != nil {
+				return 0, nil, err
+			}
+		}
+		if messageType == TextMessage && !utf8.Valid(data) {
+			return 0, nil, ws.failConnection(CloseInvalidFramePayloadData, "invalid utf-8 text")
+		}
+		return messageType, data, nil
+	}
+}
+
+// 读取一条消息并解析 json
+func (ws *WebSocketConn) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// 发送一条完整消息，协商了压缩时数据消息会被压缩
+func (ws *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		return ws.writeControl(messageType, data)
+	default:
+		return fmt.Errorf("websocket: unknown message type %d", messageType)
+	}
+	rsv1 := false
+	if ws.compress {
+		compressed, err := deflate(data)
+		if err != nil {
+			return err
+		}
+		data, rsv1 = compressed, true
+	}
+	return ws.writeFrame(true, rsv1, messageType, data)
+}
+
+func (ws *WebSocketConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(TextMessage, data)
+}
+
+func (ws *WebSocketConn) Ping(data []byte) error {
+	return ws.writeControl(PingMessage, data)
+}
+
+// 发送关闭帧，对方回复关闭帧后 ReadMessage 返回 *CloseError
+func (ws *WebSocketConn) WriteClose(code int, text string) error {
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+	if len(payload) > maxControlFramePayload {
+		payload = payload[:maxControlFramePayload]
+	}
+	return ws.writeControl(CloseMessage, payload)
+}
+
+// 关闭底层连接，还没有发送过关闭帧时先发送 1000 正常关闭
+func (ws *WebSocketConn) Close() error {
+	var err error
+	ws.closeOnce.Do(func() {
+		ws.writeMux.Lock()
+		sent := ws.closeSent
+		ws.writeMux.Unlock()
+		if !sent {
+			ws.WriteClose(CloseNormalClosure, "")
+		}
+		err = ws.conn.Close()
+	})
+	return err
+}
+
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  int
+	payload []byte
+}
+
+func (ws *WebSocketConn) readFrame() (wsFrame, error) {
+	var frame wsFrame
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(ws.reader, header); err != nil {
+		return frame, ws.readError(err)
+	}
+	frame.fin = header[0]&0x80 != 0
+	frame.rsv1 = header[0]&0x40 != 0
+	frame.opcode = int(header[0] & 0x0f)
+	if header[0]&0x30 != 0 {
+		return frame, ws.failConnection(CloseProtocolError, "reserved bits set")
+	}
+	if frame.rsv1 && !ws.compress {
+		return frame, ws.failConnection(CloseProtocolError, "rsv1 set without compression")
+	}
+	masked := header[1]&0x80 != 0
+	if !masked {
+		// 客户端发送的帧必须带掩码
+		return frame, ws.failConnection(CloseProtocolError, "frame not masked")
+	}
+	length := int64(header[1] & 0x7f)
+	isControl := frame.opcode >= CloseMessage
+	if isControl && (!frame.fin || length > maxControlFramePayload) {
+		return frame, ws.failConnection(CloseProtocolError, "invalid control frame")
+	}
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(ws.reader, ext); err != nil {
+			return frame, ws.readError(err)
+		}
+		length = int64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(ws.reader, ext); err != nil {
+			return frame, ws.readError(err)
+		}
+		length = int64(binary.BigEndian.Uint64(ext))
+		if length < 0 {
+			return frame, ws.failConnection(CloseProtocolError, "invalid payload length")
+		}
+	}
+	if length > ws.readLimit {
+		return frame, ws.failConnection(CloseMessageTooBig, "message too big")
+	}
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(ws.reader, mask); err != nil {
+		return frame, ws.readError(err)
+	}
+	frame.payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, frame.payload); err != nil {
+		return frame, ws.readError(err)
+	}
+	for i := range frame.payload {
+		frame.payload[i] ^= mask[i%4]
+	}
+	return frame, nil
+}
+
+func (ws *WebSocketConn) readError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &CloseError{Code: CloseAbnormalClosure, Text: "unexpected EOF"}
+	}
+	return err
+}
+
+func (ws *WebSocketConn) handlePing(data []byte) error {
+	if ws.pingHandler != nil {
+		return ws.pingHandler(data)
+	}
+	err := ws.writeControl(PongMessage, data)
+	if errors.Is(err, ErrWebSocketClosed) {
+		return nil
+	}
+	return err
+}
+
+// 收到关闭帧，回复同样的状态码
+func (ws *WebSocketConn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return ws.failConnection(CloseProtocolError, "invalid close payload")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return ws.failConnection(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Text) {
+			return ws.failConnection(CloseInvalidFramePayloadData, "invalid utf-8 close reason")
+		}
+	}
+	replyCode := closeErr.Code
+	if replyCode == CloseNoStatusReceived {
+		replyCode = CloseNormalClosure
+	}
+	ws.WriteClose(replyCode, "")
+	return closeErr
+}
+
+func validCloseCode(code int) bool {
+	switch code {
+	case CloseNoStatusReceived, CloseAbnormalClosure, 1004, 1015:
+		return false
+	}
+	return (code >= 1000 && code <= 1014) || (code >= 3000 && code <= 4999)
+}
+
+// 协议错误：发送关闭帧后返回错误，由调用方结束处理
+func (ws *WebSocketConn) failConnection(code int, text string) error {
+	ws.WriteClose(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+func (ws *WebSocketConn) writeControl(opcode int, data []byte) error {
+	if len(data) > maxControlFramePayload {
+		return errors.New("websocket: control frame payload too large")
+	}
+	return ws.writeFrame(true, false, opcode, data)
+}
+
+// 服务端发送的帧不带掩码
+func (ws *WebSocketConn) writeFrame(fin, rsv1 bool, opcode int, payload []byte) error {
+	ws.writeMux.Lock()
+	defer ws.writeMux.Unlock()
+	if ws.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+	header := make([]byte, 0, 10)
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	header = append(header, b0)
+	switch length := len(payload); {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xffff:
+		header = append(header, 126, byte(length>>8), byte(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+	if ws.writeTimeout > 0 {
+		ws.conn.SetWriteDeadline(time.Now().Add(ws.writeTimeout))
+	}
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// permessage-deflate：压缩后去掉末尾的 0x00 0x00 0xff 0xff，见 RFC 7692
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{0x00, 0x00, 0xff, 0xff}), nil
+}
+
+func (ws *WebSocketConn) inflate(data []byte) ([]byte, error) {
+	reader := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader([]byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff})))
+	defer reader.Close()
+	out, err := io.ReadAll(io.LimitReader(reader, ws.readLimit+1))
+	if err != nil {
+		return nil, ws.failConnection(CloseInvalidFramePayloadData, "invalid compressed data")
+	}
+	if int64(len(out)) > ws.readLimit {
+		return nil, ws.failConnection(CloseMessageTooBig, "message too big")
+	}
+	return out, nil
+}
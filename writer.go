@@ -0,0 +1,82 @@
+// 记录状态码和输出字节数的 ResponseWriter
+package httpserver
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// 包装 http.ResponseWriter，记录实际写出的状态码和 body 字节数，用于日志、监控
+// 文件、事件流等直接写 responseWriter 的响应也能统计到
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+	// 第一次写出前调用一次，用于在 header 发出前写入 session cookie 等
+	beforeWrite func()
+}
+
+func (self *responseRecorder) runBeforeWrite() {
+	if self.beforeWrite != nil {
+		fn := self.beforeWrite
+		self.beforeWrite = nil
+		fn()
+	}
+}
+
+func (self *responseRecorder) WriteHeader(code int) {
+	self.runBeforeWrite()
+	if self.status == 0 {
+		self.status = code
+	}
+	self.ResponseWriter.WriteHeader(code)
+}
+
+func (self *responseRecorder) Write(data []byte) (int, error) {
+	self.runBeforeWrite()
+	if self.status == 0 {
+		self.status = http.StatusOK
+	}
+	n, err := self.ResponseWriter.Write(data)
+	self.size += int64(n)
+	return n, err
+}
+
+// 保留底层的 sendfile 优化
+func (self *responseRecorder) ReadFrom(r io.Reader) (int64, error) {
+	self.runBeforeWrite()
+	if self.status == 0 {
+		self.status = http.StatusOK
+	}
+	var n int64
+	var err error
+	if rf, ok := self.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(self.ResponseWriter, r)
+	}
+	self.size += n
+	return n, err
+}
+
+func (self *responseRecorder) Flush() {
+	self.runBeforeWrite()
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (self *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := self.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+// 供 http.ResponseController 使用
+func (self *responseRecorder) Unwrap() http.ResponseWriter {
+	return self.ResponseWriter
+}